@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// ChromaOptions configure uploads to a Chroma server.
+type ChromaOptions struct {
+	URL        string
+	Collection string
+	// BatchSize is the number of chunks per upsert request.
+	BatchSize int
+	// MaxRetries is how often a request failing with a timeout, a connection error or a
+	// 429 or 5xx response is retried, waiting RetryBackoff before the first retry and
+	// twice as long before each further one (up to maxChromaBackoff).
+	MaxRetries   int
+	RetryBackoff time.Duration
+	Auth         ChromaAuth
+	// API selects the REST API version (see the chromaAPI constants). Tenant and Database
+	// scope the collection; empty means Chroma's defaults.
+	API      string
+	Tenant   string
+	Database string
+	// Flatten turns metadata into values Chroma accepts; the zero value means
+	// defaultMetadataFlattening.
+	Flatten MetadataFlattening
+}
+
+const (
+	// chromaAPIAuto uses v2 if the server answers its heartbeat, v1 otherwise.
+	chromaAPIAuto = "auto"
+	// chromaAPIV1 uses /api/v1, passing tenant and database as query parameters.
+	chromaAPIV1 = "v1"
+	// chromaAPIV2 uses /api/v2/tenants/{tenant}/databases/{database}.
+	chromaAPIV2 = "v2"
+
+	defaultChromaTenant   = "default_tenant"
+	defaultChromaDatabase = "default_database"
+)
+
+const (
+	// defaultChromaURL is where a local Chroma server listens.
+	defaultChromaURL = "http://localhost:8000"
+	// defaultChromaCollection is the collection chunks are uploaded to.
+	defaultChromaCollection = "go_code_chunks"
+	// defaultChromaBatchSize is how many chunks are sent per upsert request.
+	defaultChromaBatchSize = 100
+	// chromaPageSize is how many IDs are fetched per get request.
+	chromaPageSize = 1000
+
+	defaultChromaRetries = 5
+	defaultChromaBackoff = time.Second
+	maxChromaBackoff     = time.Minute
+)
+
+// chromaClient talks to the REST API of a Chroma server.
+type chromaClient struct {
+	baseURL string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+	auth    ChromaAuth
+
+	api      string
+	tenant   string
+	database string
+	flatten  MetadataFlattening
+}
+
+func newChromaClient(opts ChromaOptions) (*chromaClient, error) {
+	transport, err := opts.Auth.transport()
+	if err != nil {
+		return nil, err
+	}
+	flatten := opts.Flatten
+	if flatten == (MetadataFlattening{}) {
+		flatten = defaultMetadataFlattening
+	}
+	return &chromaClient{
+		baseURL: strings.TrimRight(opts.URL, "/"),
+		http:    &http.Client{Timeout: 60 * time.Second, Transport: transport},
+		retries: opts.MaxRetries,
+		backoff: opts.RetryBackoff,
+		auth:    opts.Auth,
+
+		api:      opts.API,
+		tenant:   opts.Tenant,
+		database: opts.Database,
+		flatten:  flatten,
+	}, nil
+}
+
+// chromaStatusError is a response from Chroma with a non-2xx status.
+type chromaStatusError struct {
+	Path       string
+	Status     string
+	StatusCode int
+	Body       string
+}
+
+func (e *chromaStatusError) Error() string {
+	return fmt.Sprintf("Chroma returned %s for %s: %s", e.Status, e.Path, e.Body)
+}
+
+// post sends body as JSON to path and decodes the response into result, if not nil.
+func (c *chromaClient) post(ctx context.Context, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.request(ctx, http.MethodPost, path, data, result)
+}
+
+// request sends data (if not nil) to path and decodes the response into result, if not
+// nil. Transient failures are retried with exponential backoff; every request the client
+// makes is idempotent, so repeating one is safe.
+func (c *chromaClient) request(ctx context.Context, method, path string, data []byte, result interface{}) error {
+	delay := c.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := c.send(ctx, method, path, data, result)
+		if err == nil || !retry || attempt > c.retries || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Warning: %v; retrying in %s (retry %d of %d)", err, delay, attempt, c.retries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		if delay *= 2; delay > maxChromaBackoff {
+			delay = maxChromaBackoff
+		}
+	}
+}
+
+// send makes one request for request and reports whether a failure is worth retrying.
+func (c *chromaClient) send(ctx context.Context, method, path string, data []byte, result interface{}) (bool, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return false, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to reach Chroma: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read Chroma response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, &chromaStatusError{Path: path, Status: resp.Status, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	if result == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return false, fmt.Errorf("failed to decode Chroma response: %w", err)
+	}
+	return false, nil
+}
+
+// detectAPI resolves chromaAPIAuto: servers with a v2 heartbeat endpoint get v2.
+func (c *chromaClient) detectAPI(ctx context.Context) error {
+	if c.api != "" && c.api != chromaAPIAuto {
+		return nil
+	}
+	err := c.request(ctx, http.MethodGet, "/api/v2/heartbeat", nil, nil)
+	var status *chromaStatusError
+	switch {
+	case err == nil:
+		c.api = chromaAPIV2
+	case errors.As(err, &status) && (status.StatusCode == http.StatusNotFound || status.StatusCode == http.StatusGone):
+		c.api = chromaAPIV1
+	default:
+		return fmt.Errorf("failed to detect the Chroma API version: %w", err)
+	}
+	return nil
+}
+
+// collectionsPath is the path of the collections endpoint in the tenant and database.
+func (c *chromaClient) collectionsPath() string {
+	if c.api == chromaAPIV2 {
+		tenant, database := c.tenant, c.database
+		if tenant == "" {
+			tenant = defaultChromaTenant
+		}
+		if database == "" {
+			database = defaultChromaDatabase
+		}
+		return "/api/v2/tenants/" + url.PathEscape(tenant) + "/databases/" + url.PathEscape(database) + "/collections"
+	}
+	return "/api/v1/collections"
+}
+
+// collectionPath is the path of an operation on a collection, such as "upsert".
+func (c *chromaClient) collectionPath(collectionID, operation string) string {
+	return c.collectionsPath() + "/" + url.PathEscape(collectionID) + "/" + operation
+}
+
+// collectionID returns the ID of the named collection, creating it if needed.
+func (c *chromaClient) collectionID(ctx context.Context, name string) (string, error) {
+	var collection struct {
+		ID string `json:"id"`
+	}
+	path := c.collectionsPath()
+	if c.api != chromaAPIV2 && (c.tenant != "" || c.database != "") {
+		query := url.Values{}
+		if c.tenant != "" {
+			query.Set("tenant", c.tenant)
+		}
+		if c.database != "" {
+			query.Set("database", c.database)
+		}
+		path += "?" + query.Encode()
+	}
+	body := map[string]interface{}{"name": name, "get_or_create": true}
+	if err := c.post(ctx, path, body, &collection); err != nil {
+		return "", fmt.Errorf("failed to get collection %s: %w", name, err)
+	}
+	return collection.ID, nil
+}
+
+// upsert adds chunks to a collection, replacing the documents and metadata of chunks
+// whose IDs are already present, so re-indexing updates chunks in place. Embeddings are
+// sent when every chunk has one; otherwise the collection's embedding function computes
+// them.
+func (c *chromaClient) upsert(ctx context.Context, collectionID string, chunks []extract.ChromaDocument) error {
+	body := struct {
+		IDs        []string                 `json:"ids"`
+		Documents  []string                 `json:"documents"`
+		Metadatas  []map[string]interface{} `json:"metadatas"`
+		Embeddings [][]float32              `json:"embeddings,omitempty"`
+	}{}
+	embedded := true
+	for _, chunk := range chunks {
+		body.IDs = append(body.IDs, chunk.ID)
+		body.Documents = append(body.Documents, chunk.Document)
+		body.Metadatas = append(body.Metadatas, flattenMetadata(chunk.Metadata, c.flatten))
+		body.Embeddings = append(body.Embeddings, chunk.Embedding)
+		embedded = embedded && chunk.Embedding != nil
+	}
+	if !embedded {
+		body.Embeddings = nil
+	}
+	return c.post(ctx, c.collectionPath(collectionID, "upsert"), body, nil)
+}
+
+// ids returns the IDs of the collection's chunks matching the where filter, fetched
+// pageSize at a time.
+func (c *chromaClient) ids(ctx context.Context, collectionID string, where map[string]interface{}, pageSize int) ([]string, error) {
+	var ids []string
+	for {
+		var page struct {
+			IDs []string `json:"ids"`
+		}
+		body := map[string]interface{}{"where": where, "include": []string{}, "limit": pageSize, "offset": len(ids)}
+		if err := c.post(ctx, c.collectionPath(collectionID, "get"), body, &page); err != nil {
+			return ids, err
+		}
+		ids = append(ids, page.IDs...)
+		if len(page.IDs) < pageSize {
+			return ids, nil
+		}
+	}
+}
+
+// delete removes the chunks with the given IDs from a collection.
+func (c *chromaClient) delete(ctx context.Context, collectionID string, ids []string) error {
+	body := map[string]interface{}{"ids": ids}
+	return c.post(ctx, c.collectionPath(collectionID, "delete"), body, nil)
+}
+
+// validateChromaSync checks that -chroma-sync can run: deleting what was not uploaded is
+// only safe when every chunk of the projects was extracted and uploaded.
+func validateChromaSync(opts extract.ExtractOptions, out OutputOptions) error {
+	switch {
+	case out.Incremental:
+		return fmt.Errorf("-chroma-sync cannot be combined with -incremental")
+	case opts.TimeBudget > 0:
+		return fmt.Errorf("-chroma-sync cannot be combined with -time-budget")
+	case len(opts.Packages) > 0:
+		return fmt.Errorf("-chroma-sync cannot be combined with -packages or package patterns")
+	case len(opts.Paths.Include) > 0 || len(opts.Paths.Exclude) > 0:
+		return fmt.Errorf("-chroma-sync cannot be combined with -include or -exclude")
+	case opts.Generated == extract.GeneratedSkip:
+		return fmt.Errorf("-chroma-sync cannot be combined with -generated=%s", extract.GeneratedSkip)
+	case opts.Vendor != extract.VendorSkip:
+		return fmt.Errorf("-chroma-sync cannot be combined with -vendor=%s", opts.Vendor)
+	case opts.SkipMain || opts.SkipCmd:
+		return fmt.Errorf("-chroma-sync cannot be combined with -skip-main or -skip-cmd")
+	}
+	return nil
+}
+
+// validateChromaOptions checks the batching, retry and auth settings.
+func validateChromaOptions(opts ChromaOptions) error {
+	if err := opts.Auth.validate(); err != nil {
+		return err
+	}
+	if opts.Flatten != (MetadataFlattening{}) {
+		if err := validateMetadataFlattening(opts.Flatten); err != nil {
+			return err
+		}
+	}
+	switch opts.API {
+	case "", chromaAPIAuto, chromaAPIV1, chromaAPIV2:
+	default:
+		return fmt.Errorf("unknown Chroma API %q (want %s, %s or %s)", opts.API, chromaAPIAuto, chromaAPIV1, chromaAPIV2)
+	}
+	if opts.BatchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", opts.BatchSize)
+	}
+	if opts.MaxRetries < 0 || opts.RetryBackoff < 0 {
+		return fmt.Errorf("retries and backoff must not be negative")
+	}
+	return nil
+}
+
+// chromaSink upserts chunks into a Chroma collection in batches of batchSize as they are
+// written; Flush sends a partial batch. Chunks flagged embedding_skipped are left out.
+// The IDs of upserted chunks and the project IDs of all chunks are kept for deleteStale.
+type chromaSink struct {
+	client       *chromaClient
+	collection   string
+	collectionID string
+	batchSize    int
+	pending      []extract.ChromaDocument
+	batches      int
+	sent         int
+
+	written  map[string]bool
+	projects map[string]bool
+}
+
+// newChromaSink connects to the collection, creating it if needed.
+func newChromaSink(ctx context.Context, opts ChromaOptions) (*chromaSink, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultChromaBatchSize
+	}
+	client, err := newChromaClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.detectAPI(ctx); err != nil {
+		return nil, err
+	}
+	id, err := client.collectionID(ctx, opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+	return &chromaSink{
+		client:       client,
+		collection:   opts.Collection,
+		collectionID: id,
+		batchSize:    opts.BatchSize,
+		written:      make(map[string]bool),
+		projects:     make(map[string]bool),
+	}, nil
+}
+
+func (s *chromaSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	for _, chunk := range chunks {
+		if project, _ := chunk.Metadata["project_id"].(string); project != "" {
+			s.projects[project] = true
+		}
+		// A skipped chunk counts as not written, so deleteStale removes its old version.
+		if skipped, _ := chunk.Metadata["embedding_skipped"].(bool); skipped {
+			continue
+		}
+		s.written[chunk.ID] = true
+		s.pending = append(s.pending, chunk)
+		if len(s.pending) == s.batchSize {
+			if err := s.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *chromaSink) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := s.pending
+	s.batches++
+	if err := s.client.upsert(ctx, s.collectionID, batch); err != nil {
+		return fmt.Errorf("failed to upsert batch %d (%d chunks, %s..%s): %w", s.batches, len(batch), batch[0].ID, batch[len(batch)-1].ID, err)
+	}
+	s.sent += len(batch)
+	s.pending = s.pending[:0]
+	return nil
+}
+
+func (s *chromaSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// deleteStale deletes the collection's chunks of the projects written to s that were not
+// written themselves, such as the chunks of removed or renamed symbols, so the collection
+// matches the extracted source tree. It must only be called after a complete extraction
+// and returns the number of chunks deleted.
+func (s *chromaSink) deleteStale(ctx context.Context) (int, error) {
+	if len(s.projects) == 0 {
+		log.Printf("Warning: no chunks with project_id metadata were uploaded; not deleting stale chunks")
+		return 0, nil
+	}
+	projects := make([]string, 0, len(s.projects))
+	for project := range s.projects {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	where := map[string]interface{}{"project_id": map[string]interface{}{"$in": projects}}
+	ids, err := s.client.ids(ctx, s.collectionID, where, chromaPageSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list chunks of %s: %w", strings.Join(projects, ", "), err)
+	}
+	var stale []string
+	for _, id := range ids {
+		if !s.written[id] {
+			stale = append(stale, id)
+		}
+	}
+	for start := 0; start < len(stale); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		if err := s.client.delete(ctx, s.collectionID, stale[start:end]); err != nil {
+			return start, fmt.Errorf("failed to delete stale chunks: %w", err)
+		}
+	}
+	return len(stale), nil
+}
+
+// String describes the destination in messages.
+func (s *chromaSink) String() string {
+	return fmt.Sprintf("Chroma collection %s at %s", s.collection, s.client.baseURL)
+}
+
+// runUpload implements the "upload" subcommand: upload -chroma-url URL -collection NAME
+// file.json... upserts the chunks of extracted files into a Chroma collection. It
+// returns the number of chunks sent.
+func runUpload(ctx context.Context, args []string) (int, error) {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	var opts ChromaOptions
+	fs.StringVar(&opts.URL, "chroma-url", defaultChromaURL, "Base URL of the Chroma server")
+	fs.StringVar(&opts.Collection, "collection", defaultChromaCollection, "Chroma collection to upsert the chunks into (created if missing)")
+	fs.IntVar(&opts.BatchSize, "batch-size", defaultChromaBatchSize, "Number of chunks per upsert request")
+	fs.IntVar(&opts.MaxRetries, "max-retries", defaultChromaRetries, "Retries of a request failing with a timeout, connection error or 429/5xx response")
+	fs.DurationVar(&opts.RetryBackoff, "retry-backoff", defaultChromaBackoff, "Wait before the first retry, doubled for each further one")
+	fs.StringVar(&opts.API, "chroma-api", chromaAPIAuto, "Chroma REST API version: auto, v1 or v2")
+	fs.StringVar(&opts.Tenant, "tenant", "", "Chroma tenant of the collection (default "+defaultChromaTenant+")")
+	fs.StringVar(&opts.Database, "database", "", "Chroma database of the collection (default "+defaultChromaDatabase+")")
+	addChromaAuthFlags(fs, &opts.Auth)
+	addMetadataFlatteningFlags(fs, &opts.Flatten)
+	sync := fs.Bool("sync", false, "Afterwards delete the collection's chunks of the uploaded projects that are not in the files")
+	encryptKeyFile := fs.String("encrypt-key-file", "", "Key for encrypted chunk files (or set $"+encryptionKeyEnv+")")
+	fs.Parse(args)
+	opts.Auth = opts.Auth.withEnv()
+	if err := validateChromaOptions(opts); err != nil {
+		return 0, err
+	}
+	if fs.NArg() == 0 {
+		return 0, fmt.Errorf("no chunk files given: upload [flags] file.json...")
+	}
+	key, err := loadEncryptionKey(*encryptKeyFile)
+	if err != nil {
+		return 0, err
+	}
+	sink, err := newChromaSink(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range fs.Args() {
+		chunks, err := readChunkFile(path, key)
+		if err != nil {
+			return sink.sent, err
+		}
+		if err := sink.Write(ctx, chunks); err != nil {
+			return sink.sent, err
+		}
+	}
+	if err := sink.Flush(ctx); err != nil {
+		return sink.sent, err
+	}
+	if *sync {
+		deleted, err := sink.deleteStale(ctx)
+		if err != nil {
+			return sink.sent, err
+		}
+		fmt.Printf("Deleted %d stale chunks\n", deleted)
+	}
+	return sink.sent, nil
+}
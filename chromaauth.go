@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Environment variables used for Chroma credentials and TLS files not set by flags.
+const (
+	chromaTokenEnv    = "CHROMA_EXTRACT_TOKEN"
+	chromaUserEnv     = "CHROMA_EXTRACT_USER"
+	chromaPasswordEnv = "CHROMA_EXTRACT_PASSWORD"
+	chromaCAFileEnv   = "CHROMA_EXTRACT_CA_FILE"
+	chromaCertFileEnv = "CHROMA_EXTRACT_CERT_FILE"
+	chromaKeyFileEnv  = "CHROMA_EXTRACT_KEY_FILE"
+)
+
+// defaultChromaTokenHeader carries the token as "Bearer <token>"; any other header gets
+// the bare token (Chroma's own token auth uses X-Chroma-Token).
+const defaultChromaTokenHeader = "Authorization"
+
+// ChromaAuth holds the credentials and TLS files for a Chroma server behind a gateway.
+type ChromaAuth struct {
+	Token       string
+	TokenHeader string
+	Username    string
+	Password    string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// CertFile and KeyFile are the client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+}
+
+// addChromaAuthFlags defines the credential and TLS flags on fs.
+func addChromaAuthFlags(fs *flag.FlagSet, auth *ChromaAuth) {
+	fs.StringVar(&auth.Token, "chroma-token", "", "Token sent to Chroma (or set $"+chromaTokenEnv+")")
+	fs.StringVar(&auth.TokenHeader, "chroma-token-header", defaultChromaTokenHeader, "Header carrying -chroma-token: Authorization (as a Bearer token) or e.g. X-Chroma-Token")
+	fs.StringVar(&auth.Username, "chroma-user", "", "User for basic auth with Chroma (or set $"+chromaUserEnv+"; the password is read from $"+chromaPasswordEnv+")")
+	fs.StringVar(&auth.CAFile, "chroma-ca-file", "", "PEM bundle of extra CAs to trust for Chroma (or set $"+chromaCAFileEnv+")")
+	fs.StringVar(&auth.CertFile, "chroma-cert-file", "", "Client certificate for Chroma (or set $"+chromaCertFileEnv+")")
+	fs.StringVar(&auth.KeyFile, "chroma-key-file", "", "Key of the client certificate for Chroma (or set $"+chromaKeyFileEnv+")")
+}
+
+// withEnv fills the settings not given by flags from the environment.
+func (auth ChromaAuth) withEnv() ChromaAuth {
+	for _, setting := range []struct {
+		value *string
+		env   string
+	}{
+		{&auth.Token, chromaTokenEnv},
+		{&auth.Username, chromaUserEnv},
+		{&auth.Password, chromaPasswordEnv},
+		{&auth.CAFile, chromaCAFileEnv},
+		{&auth.CertFile, chromaCertFileEnv},
+		{&auth.KeyFile, chromaKeyFileEnv},
+	} {
+		if *setting.value == "" {
+			*setting.value = os.Getenv(setting.env)
+		}
+	}
+	if auth.TokenHeader == "" {
+		auth.TokenHeader = defaultChromaTokenHeader
+	}
+	return auth
+}
+
+func (auth ChromaAuth) validate() error {
+	if auth.Token != "" && auth.Username != "" && strings.EqualFold(auth.TokenHeader, "Authorization") {
+		return errors.New("a Chroma token in the Authorization header cannot be combined with basic auth")
+	}
+	if (auth.CertFile == "") != (auth.KeyFile == "") {
+		return errors.New("a Chroma client certificate needs both a certificate and a key file")
+	}
+	return nil
+}
+
+// transport returns an HTTP transport using the CA bundle and client certificate.
+func (auth ChromaAuth) transport() (http.RoundTripper, error) {
+	if auth.CAFile == "" && auth.CertFile == "" {
+		return http.DefaultTransport, nil
+	}
+	config := &tls.Config{}
+	if auth.CAFile != "" {
+		pem, err := ioutil.ReadFile(auth.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", auth.CAFile)
+		}
+		config.RootCAs = roots
+	}
+	if auth.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(auth.CertFile, auth.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
+
+// authorize adds the credential headers to req.
+func (auth ChromaAuth) authorize(req *http.Request) {
+	if auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	if auth.Token == "" {
+		return
+	}
+	if strings.EqualFold(auth.TokenHeader, "Authorization") {
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		return
+	}
+	req.Header.Set(auth.TokenHeader, auth.Token)
+}
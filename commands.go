@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// ChunkDiff is the difference between two chunk files, as printed by the diff command.
+type ChunkDiff struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// diffChunks compares two chunk sets by ID and content fingerprint.
+func diffChunks(old, next []extract.ChromaDocument) (*ChunkDiff, error) {
+	oldFingerprints, err := snapshotFingerprints(old)
+	if err != nil {
+		return nil, err
+	}
+	newFingerprints, err := snapshotFingerprints(next)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ChunkDiff{Added: []string{}, Updated: []string{}, Deleted: []string{}}
+	for id, fingerprint := range newFingerprints {
+		oldFingerprint, ok := oldFingerprints[id]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, id)
+		case oldFingerprint != fingerprint:
+			diff.Updated = append(diff.Updated, id)
+		default:
+			diff.Unchanged++
+		}
+	}
+	for id := range oldFingerprints {
+		if _, ok := newFingerprints[id]; !ok {
+			diff.Deleted = append(diff.Deleted, id)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Updated)
+	sort.Strings(diff.Deleted)
+	return diff, nil
+}
+
+// runDiff implements the "diff" subcommand: diff old.json new.json compares two
+// snapshots of a chunk set.
+func runDiff(args []string) (*ChunkDiff, error) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	encryptKeyFile := fs.String("encrypt-key-file", "", "Key for encrypted chunk files (or set $"+encryptionKeyEnv+")")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return nil, fmt.Errorf("want two chunk files: diff [flags] old.json new.json")
+	}
+	key, err := loadEncryptionKey(*encryptKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	old, err := readChunkFile(fs.Arg(0), key)
+	if err != nil {
+		return nil, err
+	}
+	next, err := readChunkFile(fs.Arg(1), key)
+	if err != nil {
+		return nil, err
+	}
+	return diffChunks(old, next)
+}
+
+// ValidationReport lists the problems found in one chunk file.
+type ValidationReport struct {
+	File     string   `json:"file"`
+	Chunks   int      `json:"chunks"`
+	Problems []string `json:"problems"`
+}
+
+// requiredMetadataFields must be present in the metadata of every chunk.
+var requiredMetadataFields = []string{"file_path", "package_name", "entity_type", "entity_name"}
+
+// validateChunks checks a chunk set against the metadata schema: every chunk needs a
+// unique non-empty ID, a document and the required metadata fields, and its metadata
+// must decode into ChunkMetadata.
+func validateChunks(chunks []extract.ChromaDocument) []string {
+	problems := []string{}
+	seen := make(map[string]bool)
+	for i, chunk := range chunks {
+		name := chunk.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+			problems = append(problems, fmt.Sprintf("chunk %s has no ID", name))
+		} else if seen[chunk.ID] {
+			problems = append(problems, fmt.Sprintf("chunk ID %s is not unique", chunk.ID))
+		}
+		seen[chunk.ID] = true
+		if chunk.Document == "" {
+			problems = append(problems, fmt.Sprintf("chunk %s has an empty document", name))
+		}
+		for _, field := range requiredMetadataFields {
+			if _, ok := chunk.Metadata[field]; !ok {
+				problems = append(problems, fmt.Sprintf("chunk %s has no %s", name, field))
+			}
+		}
+		if _, err := chunk.TypedMetadata(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// runValidate implements the "validate" subcommand: validate file.json... checks chunk
+// files before they are uploaded.
+func runValidate(args []string) ([]ValidationReport, error) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	encryptKeyFile := fs.String("encrypt-key-file", "", "Key for encrypted chunk files (or set $"+encryptionKeyEnv+")")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return nil, fmt.Errorf("no chunk files given: validate [flags] file.json...")
+	}
+	key, err := loadEncryptionKey(*encryptKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var reports []ValidationReport
+	for _, path := range fs.Args() {
+		chunks, err := readChunkFile(path, key)
+		if err != nil {
+			reports = append(reports, ValidationReport{File: path, Problems: []string{err.Error()}})
+			continue
+		}
+		reports = append(reports, ValidationReport{File: path, Chunks: len(chunks), Problems: validateChunks(chunks)})
+	}
+	return reports, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// subcommands are the words accepted as the first argument, before any flags.
+var subcommands = []string{"completion", "describe", "diff", "extract", "get", "init", "serve", "upload", "validate"}
+
+// writeCompletion prints a completion script for shell covering the subcommands and
+// every flag registered on the flag sets.
+func writeCompletion(w io.Writer, shell, program string, flagSets ...*flag.FlagSet) error {
+	program = filepath.Base(program)
+	usages := make(map[string]string)
+	var flagNames []string
+	for _, fs := range flagSets {
+		fs.VisitAll(func(f *flag.Flag) {
+			if _, seen := usages[f.Name]; !seen {
+				usages[f.Name] = f.Usage
+				flagNames = append(flagNames, f.Name)
+			}
+		})
+	}
+	sort.Strings(flagNames)
+
+	switch shell {
+	case "bash":
+		words := append([]string{}, subcommands...)
+		for _, name := range flagNames {
+			words = append(words, "-"+name)
+		}
+		fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+		fmt.Fprintf(w, "%s() {\n", fn)
+		fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(w, "\tif [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == completion ]]; then\n")
+		fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\"))\n\t\treturn\n\tfi\n")
+		fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(words, " "))
+		fmt.Fprintf(w, "}\ncomplete -o default -F %s %s\n", fn, program)
+	case "zsh":
+		fmt.Fprintf(w, "#compdef %s\n\n_arguments \\\n", program)
+		for _, name := range flagNames {
+			usage := strings.NewReplacer("[", "(", "]", ")", "'", "").Replace(usages[name])
+			fmt.Fprintf(w, "\t'-%s[%s]' \\\n", name, usage)
+		}
+		fmt.Fprintf(w, "\t'1:command:(%s)'\n", strings.Join(subcommands, " "))
+	case "fish":
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a '%s'\n", program, strings.Join(subcommands, " "))
+		fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n", program)
+		for _, name := range flagNames {
+			usage := strings.ReplaceAll(usages[name], "'", "")
+			fmt.Fprintf(w, "complete -c %s -o %s -d '%s'\n", program, name, usage)
+		}
+	default:
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+	return nil
+}
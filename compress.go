@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+// validateCompression checks the value of -compress; "" selects the compression by the
+// output file's extension.
+func validateCompression(compression string) error {
+	switch compression {
+	case "", compressNone, compressGzip, compressZstd:
+		return nil
+	}
+	return fmt.Errorf("unknown compression %q (want %s, %s or %s)", compression, compressNone, compressGzip, compressZstd)
+}
+
+// outputCompression resolves the compression of path: compression if set, otherwise
+// gzip for .gz files, zstd for .zst files and none for everything else.
+func outputCompression(compression, path string) string {
+	if compression != "" {
+		return compression
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return compressGzip
+	case ".zst":
+		return compressZstd
+	}
+	return compressNone
+}
+
+// trimCompressionExt strips a .gz or .zst extension, so "chunks.jsonl.zst" is
+// recognized as JSONL.
+func trimCompressionExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".zst":
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return path
+}
+
+// compressWriter is a compressing writer; Flush makes the data written so far
+// decodable, Close writes the end of the stream but does not close the underlying writer.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// newCompressWriter wraps w in a compressing writer.
+func newCompressWriter(w io.Writer, compression string) (compressWriter, error) {
+	switch compression {
+	case compressGzip:
+		return gzip.NewWriter(w), nil
+	case compressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nopCompressWriter{bufio.NewWriter(w)}, nil
+}
+
+// nopCompressWriter buffers writes without compressing them.
+type nopCompressWriter struct {
+	*bufio.Writer
+}
+
+func (w nopCompressWriter) Close() error { return w.Flush() }
+
+// compressData compresses data as a whole.
+func compressData(data []byte, compression string) ([]byte, error) {
+	if compression == compressNone || compression == "" {
+		return data, nil
+	}
+	var b bytes.Buffer
+	w, err := newCompressWriter(&b, compression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// Magic numbers of the supported compression formats.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressData decompresses gzip and zstd data, recognized by their magic numbers;
+// other data is returned unchanged.
+func decompressData(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer r.Close()
+		plain, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		return plain, nil
+	case bytes.HasPrefix(data, zstdMagic):
+		r, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		plain, err := r.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		return plain, nil
+	}
+	return data, nil
+}
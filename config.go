@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFiles are looked up, in order, in the working directory when -config is
+// not given. The first one found is used.
+var defaultConfigFiles = []string{"chroma-extract.yaml", "chroma-extract.yml", "chroma-extract.toml", ".chroma-extract.json"}
+
+// ExtractConfig describes a reproducible extraction run; it can be checked into a
+// repository as YAML, TOML or JSON. Root (or Roots, for several projects extracted into
+// one chunk set) and Output set -project and -out. Chunking,
+// Metadata and Flags all hold flag values keyed by flag name; the first two only group
+// related settings for readability. Relative paths are resolved against the directory
+// of the config file. Flags given on the command line take precedence.
+type ExtractConfig struct {
+	Root     string                 `json:"root,omitempty"`
+	Roots    []string               `json:"roots,omitempty"`
+	Output   string                 `json:"output,omitempty"`
+	Chunking map[string]interface{} `json:"chunking,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Flags    map[string]interface{} `json:"flags,omitempty"`
+	// Project records what the init wizard found when probing the project.
+	Project *ProjectProbe `json:"project,omitempty"`
+}
+
+// findConfigFile returns the config file to use: path if given, otherwise the first of
+// defaultConfigFiles that exists ("" if none).
+func findConfigFile(path string) string {
+	if path != "" {
+		return path
+	}
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads and decodes path, choosing the format by file extension.
+func loadConfigFile(path string) (*ExtractConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// YAML and TOML are decoded generically and re-encoded as JSON, so all formats share
+	// the json struct tags and number handling.
+	var generic interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &generic)
+	case ".toml":
+		var table map[string]interface{}
+		_, err = toml.Decode(string(data), &table)
+		generic = table
+	case ".json":
+		err = json.Unmarshal(data, &generic)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q (want .yaml, .yml, .toml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var config ExtractConfig
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	// Flag values keep their literal text: as float64, 10000000 would become "1e+07".
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	base := filepath.Dir(path)
+	if config.Root != "" && len(config.Roots) > 0 {
+		return nil, fmt.Errorf("config file %s sets both root and roots", path)
+	}
+	if config.Root != "" && !filepath.IsAbs(config.Root) {
+		config.Root = filepath.Join(base, config.Root)
+	}
+	for i, root := range config.Roots {
+		if !filepath.IsAbs(root) {
+			config.Roots[i] = filepath.Join(base, root)
+		}
+	}
+	if config.Output != "" && !filepath.IsAbs(config.Output) {
+		config.Output = filepath.Join(base, config.Output)
+	}
+	return &config, nil
+}
+
+// writeConfigFile encodes config in the format matching path's extension.
+func writeConfigFile(path string, config *ExtractConfig) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// Round-trip through JSON so YAML keys follow the json tags.
+		var generic interface{}
+		if data, err = json.Marshal(config); err == nil {
+			if err = json.Unmarshal(data, &generic); err == nil {
+				data, err = yaml.Marshal(generic)
+			}
+		}
+	case ".toml":
+		var generic map[string]interface{}
+		if data, err = json.Marshal(config); err == nil {
+			if err = json.Unmarshal(data, &generic); err == nil {
+				var buf bytes.Buffer
+				err = toml.NewEncoder(&buf).Encode(generic)
+				data = buf.Bytes()
+			}
+		}
+	case ".json":
+		data, err = json.MarshalIndent(config, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported config file format %q (want .yaml, .yml, .toml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// applyConfigFlags sets every flag named in config that was not set on the command line.
+func applyConfigFlags(fs *flag.FlagSet, config *ExtractConfig) error {
+	values := make(map[string]interface{})
+	for _, group := range []map[string]interface{}{config.Chunking, config.Metadata, config.Flags} {
+		for name, value := range group {
+			if _, dup := values[name]; dup {
+				return fmt.Errorf("flag %q is set more than once", name)
+			}
+			values[name] = value
+		}
+	}
+	if config.Root != "" {
+		values["project"] = config.Root
+	} else if len(config.Roots) > 0 {
+		values["project"] = strings.Join(config.Roots, ",")
+	} else if config.Project != nil && config.Project.Dir != "" {
+		values["project"] = config.Project.Dir
+	}
+	if config.Output != "" {
+		values["out"] = config.Output
+	}
+	return applyFlagDefaults(fs, values)
+}
+
+// applyFlagDefaults sets each flag in values that has not been set yet. Flags set this
+// way count as set afterwards, so layering calls gives earlier layers precedence.
+func applyFlagDefaults(fs *flag.FlagSet, values map[string]interface{}) error {
+	alreadySet := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { alreadySet[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if alreadySet[name] {
+			continue
+		}
+		if err := fs.Set(name, flagValueString(values[name])); err != nil {
+			return fmt.Errorf("value for flag %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flagValueString renders a config value as flag text; lists become comma-separated.
+func flagValueString(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = flagValueString(item)
+		}
+		return strings.Join(items, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -79,7 +79,13 @@ def main():
     documents = []
     metadatas = []
     
+    skipped = 0
     for chunk in chunks:
+        # The Go program flags generated tables and encoded blobs that would only add
+        # noise to the embedding space.
+        if chunk["metadata"].get("embedding_skipped"):
+            skipped += 1
+            continue
         ids.append(chunk["id"])
         # Correctly access the 'document' field for the code snippet
         documents.append(chunk["document"]) 
@@ -118,6 +124,9 @@ def main():
         
         metadatas.append(cleaned_metadata)
 
+    if skipped:
+        print(f"Skipping {skipped} chunks flagged as unsuitable for embedding")
+
     # Add chunks to collection in batches
     # ChromaDB will automatically generate embeddings using the provided embedding_function
     batch_size = 100
@@ -126,8 +135,8 @@ def main():
     print("\nStarting batch addition to ChromaDB...")
     
     try:
-        for i in range(0, len(chunks), batch_size):
-            end_idx = min(i + batch_size, len(chunks))
+        for i in range(0, len(ids), batch_size):
+            end_idx = min(i + batch_size, len(ids))
             batch_ids = ids[i:end_idx]
             batch_documents = documents[i:end_idx]
             batch_metadatas = metadatas[i:end_idx]
@@ -138,7 +147,7 @@ def main():
                 ids=batch_ids
             )
             total_added += len(batch_ids)
-            print(f"Added batch {i//batch_size + 1}: {total_added}/{len(chunks)} chunks (embeddings generated)")
+            print(f"Added batch {i//batch_size + 1}: {total_added}/{len(ids)} chunks (embeddings generated)")
         
         time_taken = time.time() - start_time
         print(f"Successfully added all {total_added} chunks with embeddings to the 'go_code_chunks' collection")
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// encryptedArtifactMagic prefixes every encrypted artifact so readers can tell encrypted
+// and plaintext files apart. It is followed by the GCM nonce and the sealed payload.
+var encryptedArtifactMagic = []byte("GOASTCHROMA-AESGCM-1\n")
+
+// encryptionKeyEnv names the environment variable that may hold the encryption key.
+const encryptionKeyEnv = "CHROMA_EXTRACT_KEY"
+
+// loadEncryptionKey returns the AES-256 key from keyFile, or from $CHROMA_EXTRACT_KEY when
+// keyFile is empty. Keys may be given as 64 hex characters, standard base64 of 32 bytes,
+// or (key files only) 32 raw bytes. It returns nil when no key is configured.
+func loadEncryptionKey(keyFile string) ([]byte, error) {
+	var raw []byte
+	switch {
+	case keyFile != "":
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(data) == 32 {
+			return data, nil
+		}
+		raw = data
+	case os.Getenv(encryptionKeyEnv) != "":
+		raw = []byte(os.Getenv(encryptionKeyEnv))
+	default:
+		return nil, nil
+	}
+
+	text := strings.TrimSpace(string(raw))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("encryption key must be 32 bytes (raw, 64 hex characters, or base64)")
+}
+
+// encryptArtifact seals data with AES-256-GCM under a fresh random nonce.
+func encryptArtifact(data, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(encryptedArtifactMagic)+len(nonce)+len(data)+gcm.Overhead())
+	out = append(out, encryptedArtifactMagic...)
+	out = append(out, nonce...)
+	// The magic header is authenticated as additional data so it cannot be swapped.
+	return gcm.Seal(out, nonce, data, encryptedArtifactMagic), nil
+}
+
+// decryptArtifact reverses encryptArtifact. Plaintext input (no magic header) is returned
+// unchanged so readers work with both encrypted and unencrypted artifacts.
+func decryptArtifact(data, key []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedArtifactMagic) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, errors.New("artifact is encrypted but no key was provided")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := data[len(encryptedArtifactMagic):]
+	if len(body) < gcm.NonceSize() {
+		return nil, errors.New("encrypted artifact is truncated")
+	}
+	nonce, sealed := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, encryptedArtifactMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt artifact (wrong key or corrupted file): %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// readArtifact reads a file written by writeArtifact, decrypting it if necessary.
+func readArtifact(path string, key []byte) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decryptArtifact(data, key)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// defaultCSVColumns are the metadata columns of CSV output when -csv-columns is not set.
+var defaultCSVColumns = []string{"entity_type", "entity_name", "qualified_name", "file_path", "start_line", "end_line"}
+
+// newCSVFileSink returns a sink writing a CSV file with the columns id, document and
+// the given metadata fields. Lists are joined with ", " and other structured values
+// are JSON-encoded, as for Chroma; missing fields are left empty. CSV output is meant
+// for auditing: it cannot be read back by the diff, validate and upload commands.
+func newCSVFileSink(path string, key []byte, columns []string) *lineFileSink {
+	if len(columns) == 0 {
+		columns = defaultCSVColumns
+	}
+	encodeRecord := func(record []string) ([]byte, error) {
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		w.Write(record)
+		w.Flush()
+		return b.Bytes(), w.Error()
+	}
+	header, _ := encodeRecord(append([]string{"id", "document"}, columns...))
+	return &lineFileSink{path: path, key: key, header: header, encode: func(chunk extract.ChromaDocument) ([]byte, error) {
+		metadata := flattenMetadata(chunk.Metadata, defaultMetadataFlattening)
+		record := []string{chunk.ID, chunk.Document}
+		for _, column := range columns {
+			value, ok := metadata[column]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, fmt.Sprint(value))
+		}
+		line, err := encodeRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode chunk %s as CSV: %w", chunk.ID, err)
+		}
+		return line, nil
+	}}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// Job states reported by the daemon.
+const (
+	jobQueued     = "queued"
+	jobCloning    = "cloning"
+	jobExtracting = "extracting"
+	jobWriting    = "writing"
+	jobSucceeded  = "succeeded"
+	jobFailed     = "failed"
+	jobCanceled   = "canceled"
+)
+
+// defaultDaemonAddr is the address the serve command listens on by default. It is only
+// reachable from this host; jobs are not authenticated.
+const defaultDaemonAddr = "localhost:8090"
+
+// defaultDaemonJobTimeout bounds how long one job may clone and extract.
+const defaultDaemonJobTimeout = 30 * time.Minute
+
+// daemonGitProtocols are the transports git may use for daemon checkouts; GIT_ALLOW_PROTOCOL
+// keeps helpers such as ext:: from running commands named in a repo URL.
+const daemonGitProtocols = "https:http:ssh:git:file"
+
+// remoteRepoPattern matches the repo URLs the daemon clones: http(s), ssh and git URLs
+// and scp-like "user@host:path" addresses. Anything else is a local path.
+var remoteRepoPattern = regexp.MustCompile(`^((https?|ssh|git)://|[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:)`)
+
+// collectionNamePattern restricts collection names, which become file names on disk.
+var collectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// IndexJobRequest is the body of POST /jobs.
+type IndexJobRequest struct {
+	// Repo is a git URL or a local path. Local paths without a Ref are indexed in place.
+	Repo string `json:"repo"`
+	// Ref is the branch, tag or commit to check out (default: the remote's HEAD).
+	Ref string `json:"ref,omitempty"`
+	// Collection names the output; chunks are written to <workdir>/collections/<collection>.json.
+	Collection string `json:"collection"`
+	// Packages optionally restricts extraction to these package patterns.
+	Packages []string `json:"packages,omitempty"`
+}
+
+// IndexJob is the daemon's record of one job, as returned by GET /jobs/{id}.
+type IndexJob struct {
+	ID         string          `json:"id"`
+	Request    IndexJobRequest `json:"request"`
+	State      string          `json:"state"`
+	Error      string          `json:"error,omitempty"`
+	ChunkCount int             `json:"chunk_count,omitempty"`
+	OutputFile string          `json:"output_file,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// DaemonOptions configures the indexing daemon.
+type DaemonOptions struct {
+	Addr      string
+	Workers   int
+	QueueSize int
+	WorkDir   string
+	// AllowedDirs are the directories local repos may be indexed from; without any, jobs
+	// can only name remote repositories.
+	AllowedDirs []string
+	// JobTimeout bounds each job (0 means no limit).
+	JobTimeout time.Duration
+}
+
+// indexDaemon runs a bounded pool of workers over a queue of indexing jobs.
+type indexDaemon struct {
+	ctx         context.Context
+	workDir     string
+	allowedDirs []string
+	jobTimeout  time.Duration
+	opts        extract.ExtractOptions
+	out         OutputOptions
+
+	mu      sync.Mutex
+	jobs    map[string]*IndexJob
+	cancels map[string]context.CancelFunc
+	nextID  int
+	queue   chan string
+}
+
+// serveCommand holds the flags of the serve subcommand. It has no sink flags: every job
+// writes its collection file below the work directory.
+type serveCommand struct {
+	extraction ExtractionFlags
+	output     OutputFlags
+	daemon     DaemonOptions
+	allowDirs  string
+}
+
+// flagSet defines the serve flags on a new flag set.
+func (c *serveCommand) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addExtractionFlags(fs, &c.extraction)
+	addOutputFlags(fs, &c.output)
+	fs.IntVar(&c.daemon.Workers, "workers", 2, "Number of indexing jobs run concurrently")
+	fs.IntVar(&c.daemon.QueueSize, "queue", 100, "Maximum number of queued jobs")
+	fs.StringVar(&c.daemon.WorkDir, "workdir", "chroma-extract-daemon", "Directory for checkouts and collection outputs")
+	fs.StringVar(&c.allowDirs, "allow-dir", "", "Comma-separated directories local repos may be indexed from (default: remote repos only)")
+	fs.DurationVar(&c.daemon.JobTimeout, "job-timeout", defaultDaemonJobTimeout, "Cancel jobs that run longer than this (0 means no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] [address] (default %s)\n\nFlags:\n", os.Args[0], defaultDaemonAddr)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runServe implements the "serve" subcommand: serve [flags] [address].
+func runServe(ctx context.Context, args []string) error {
+	var c serveCommand
+	fs := c.flagSet()
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		return fmt.Errorf("serve takes at most one address, got %s", strings.Join(fs.Args(), " "))
+	}
+	c.daemon.Addr = defaultDaemonAddr
+	if fs.NArg() == 1 {
+		c.daemon.Addr = fs.Arg(0)
+	}
+	c.daemon.AllowedDirs = splitList(c.allowDirs)
+	opts, err := c.extraction.options(nil)
+	if err != nil {
+		return fmt.Errorf("invalid flags: %w", err)
+	}
+	out, err := c.output.options()
+	if err != nil {
+		return fmt.Errorf("invalid flags: %w", err)
+	}
+	return runDaemon(ctx, c.daemon, opts, out)
+}
+
+// runDaemon serves the job API with the given number of concurrent workers until ctx is
+// canceled, which also cancels the running jobs. Jobs inherit the extraction options and
+// output settings given to serve.
+func runDaemon(ctx context.Context, daemon DaemonOptions, opts extract.ExtractOptions, out OutputOptions) error {
+	if daemon.Workers < 1 {
+		return fmt.Errorf("daemon needs at least one worker, got %d", daemon.Workers)
+	}
+	if daemon.JobTimeout < 0 {
+		return fmt.Errorf("daemon job timeout must not be negative")
+	}
+	var allowedDirs []string
+	for _, dir := range daemon.AllowedDirs {
+		resolved, err := resolveDir(dir)
+		if err != nil {
+			return fmt.Errorf("invalid allowed directory %s: %w", dir, err)
+		}
+		allowedDirs = append(allowedDirs, resolved)
+	}
+	if err := os.MkdirAll(filepath.Join(daemon.WorkDir, "collections"), 0755); err != nil {
+		return fmt.Errorf("failed to create daemon work directory: %w", err)
+	}
+
+	d := &indexDaemon{
+		ctx:         ctx,
+		workDir:     daemon.WorkDir,
+		allowedDirs: allowedDirs,
+		jobTimeout:  daemon.JobTimeout,
+		opts:        opts,
+		out:         out,
+		jobs:        make(map[string]*IndexJob),
+		cancels:     make(map[string]context.CancelFunc),
+		queue:       make(chan string, daemon.QueueSize),
+	}
+	for i := 0; i < daemon.Workers; i++ {
+		go d.worker()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", d.handleSubmit)
+	mux.HandleFunc("GET /jobs", d.handleList)
+	mux.HandleFunc("GET /jobs/{id}", d.handleGet)
+	mux.HandleFunc("DELETE /jobs/{id}", d.handleCancel)
+
+	server := &http.Server{Addr: daemon.Addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	log.Printf("Indexing daemon listening on %s with %d workers (work dir %s).", daemon.Addr, daemon.Workers, daemon.WorkDir)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// resolveDir returns the absolute path of a directory with symlinks resolved.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// localRepoPath returns the resolved path of a local repo if it lies within one of the
+// allowed directories.
+func (d *indexDaemon) localRepoPath(repo string) (string, error) {
+	resolved, err := resolveDir(repo)
+	if err != nil {
+		return "", fmt.Errorf("repo %s is not a remote URL or an existing local directory", repo)
+	}
+	for _, dir := range d.allowedDirs {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("local repo %s is outside the directories allowed with serve -allow-dir", repo)
+}
+
+// validateJobRequest rejects requests whose repo, ref or packages could be taken for
+// command-line options, and local repos outside the allowed directories.
+func (d *indexDaemon) validateJobRequest(req IndexJobRequest) error {
+	if req.Repo == "" {
+		return fmt.Errorf("repo is required")
+	}
+	if strings.HasPrefix(req.Repo, "-") || strings.HasPrefix(req.Ref, "-") {
+		return fmt.Errorf("repo and ref must not start with \"-\"")
+	}
+	for _, pattern := range req.Packages {
+		if strings.HasPrefix(pattern, "-") {
+			return fmt.Errorf("package patterns must not start with \"-\"")
+		}
+	}
+	if !remoteRepoPattern.MatchString(req.Repo) {
+		if _, err := d.localRepoPath(req.Repo); err != nil {
+			return err
+		}
+	}
+	if !collectionNamePattern.MatchString(req.Collection) {
+		return fmt.Errorf("collection must match %s", collectionNamePattern)
+	}
+	return nil
+}
+
+func (d *indexDaemon) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req IndexJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid job request: %v", err))
+		return
+	}
+	if err := d.validateJobRequest(req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	d.nextID++
+	job := &IndexJob{
+		ID:        strconv.Itoa(d.nextID),
+		Request:   req,
+		State:     jobQueued,
+		CreatedAt: time.Now(),
+	}
+	select {
+	case d.queue <- job.ID:
+		d.jobs[job.ID] = job
+	default:
+		d.mu.Unlock()
+		writeJSONError(w, http.StatusServiceUnavailable, "job queue is full")
+		return
+	}
+	snapshot := *job
+	d.mu.Unlock()
+
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+func (d *indexDaemon) handleList(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	jobs := make([]IndexJob, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		jobs = append(jobs, *job)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (d *indexDaemon) handleGet(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	job, ok := d.jobs[r.PathValue("id")]
+	var snapshot IndexJob
+	if ok {
+		snapshot = *job
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleCancel stops a queued or running job.
+func (d *indexDaemon) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	d.mu.Lock()
+	job, ok := d.jobs[id]
+	var snapshot IndexJob
+	if ok {
+		if cancel, running := d.cancels[id]; running {
+			cancel()
+		} else if job.State == jobQueued {
+			job.State = jobCanceled
+		}
+		snapshot = *job
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// update applies fn to the job under the daemon lock.
+func (d *indexDaemon) update(id string, fn func(job *IndexJob)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d.jobs[id])
+}
+
+func (d *indexDaemon) worker() {
+	for id := range d.queue {
+		now := time.Now()
+		var req IndexJobRequest
+		canceled := false
+		ctx, cancel := d.ctx, context.CancelFunc(func() {})
+		if d.jobTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, d.jobTimeout)
+		}
+		ctx, cancelJob := context.WithCancel(ctx)
+		d.update(id, func(job *IndexJob) {
+			canceled = job.State == jobCanceled
+			if !canceled {
+				job.StartedAt = &now
+				req = job.Request
+				d.cancels[id] = cancelJob
+			}
+		})
+		if canceled {
+			cancelJob()
+			cancel()
+			continue
+		}
+
+		chunkCount, outputFile, err := d.run(ctx, id, req)
+		jobCanceledByClient := ctx.Err() == context.Canceled && d.ctx.Err() == nil
+		cancelJob()
+		cancel()
+
+		finished := time.Now()
+		d.update(id, func(job *IndexJob) {
+			delete(d.cancels, id)
+			job.FinishedAt = &finished
+			if err != nil && jobCanceledByClient {
+				job.State = jobCanceled
+				log.Printf("Job %s (%s) was canceled.", id, req.Repo)
+				return
+			}
+			if err != nil {
+				job.State = jobFailed
+				job.Error = err.Error()
+				log.Printf("Job %s (%s) failed: %v", id, req.Repo, err)
+				return
+			}
+			job.State = jobSucceeded
+			job.ChunkCount = chunkCount
+			job.OutputFile = outputFile
+			log.Printf("Job %s (%s) indexed %d chunks into %s.", id, req.Repo, chunkCount, outputFile)
+		})
+	}
+}
+
+// run checks out the repository if necessary, extracts it and writes the collection file.
+// Local repos are indexed in place unless a ref is given.
+func (d *indexDaemon) run(ctx context.Context, id string, req IndexJobRequest) (int, string, error) {
+	repo := req.Repo
+	remote := remoteRepoPattern.MatchString(repo)
+	if !remote {
+		// Checked again: the directory may have been replaced since the job was queued.
+		local, err := d.localRepoPath(repo)
+		if err != nil {
+			return 0, "", err
+		}
+		repo = local
+	}
+	projectPath := repo
+	if remote || req.Ref != "" {
+		d.update(id, func(job *IndexJob) { job.State = jobCloning })
+		checkoutDir := filepath.Join(d.workDir, "checkouts", id)
+		if err := checkoutRepo(ctx, repo, req.Ref, checkoutDir); err != nil {
+			return 0, "", err
+		}
+		defer os.RemoveAll(checkoutDir)
+		projectPath = checkoutDir
+	}
+
+	d.update(id, func(job *IndexJob) { job.State = jobExtracting })
+	opts := d.opts
+	if len(req.Packages) > 0 {
+		opts.Packages = req.Packages
+	}
+	result, err := extract.ExtractProjects(ctx, []string{projectPath}, opts)
+	if err != nil {
+		return 0, "", err
+	}
+	// Checkouts live in per-job directories; name the project after the repository so
+	// that upload -sync of the collection matches the chunks of earlier jobs.
+	name, projectID := strings.TrimSuffix(path.Base(filepath.ToSlash(repo)), ".git"), extract.ProjectIDAt(projectPath, repo)
+	for _, chunks := range [][]extract.ChromaDocument{result.Chunks, result.Entrypoints} {
+		for i := range chunks {
+			chunks[i].Metadata["project"] = name
+			chunks[i].Metadata["project_id"] = projectID
+		}
+	}
+
+	d.update(id, func(job *IndexJob) { job.State = jobWriting })
+	out := d.out
+	out.OutputFile = filepath.Join(d.workDir, "collections", req.Collection+".json")
+	out.StatePath = filepath.Join(d.workDir, "collections", "."+req.Collection+".state.json")
+	if err := emitExtraction(ctx, result, out); err != nil {
+		return 0, "", err
+	}
+	return len(result.Chunks), out.OutputFile, nil
+}
+
+// checkoutRepo clones repo into dir and checks out ref. Branches and tags are fetched
+// shallowly; arbitrary commits fall back to a full clone.
+func checkoutRepo(ctx context.Context, repo, ref, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	os.RemoveAll(dir)
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	if err := runGit(ctx, "", append(args, "--", repo, dir)...); err == nil {
+		return nil
+	} else if ref == "" {
+		return err
+	}
+
+	os.RemoveAll(dir)
+	if err := runGit(ctx, "", "clone", "--quiet", "--", repo, dir); err != nil {
+		return err
+	}
+	// The trailing "--" makes git read ref as a revision, never as a path.
+	return runGit(ctx, dir, "checkout", "--quiet", ref, "--")
+}
+
+// runGit runs a git command, folding its output into the error on failure.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_ALLOW_PROTOCOL="+daemonGitProtocols, "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, output)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// dedupeChunks keeps the first of every set of chunks with byte-identical text, so
+// generated code and copy-pasted helpers are embedded and stored once. The kept chunk
+// records the SHA-256 of its text as "content_hash" and, when it stands for others, their
+// IDs in "duplicate_ids" and the locations of all copies (its own first) in
+// "source_locations". It returns the kept chunks in their original order and the number
+// removed.
+func dedupeChunks(chunks []extract.ChromaDocument) ([]extract.ChromaDocument, int) {
+	kept := make([]extract.ChromaDocument, 0, len(chunks))
+	first := make(map[string]int) // Content hash to index in kept.
+	for _, chunk := range chunks {
+		sum := sha256.Sum256([]byte(chunk.Document))
+		hash := hex.EncodeToString(sum[:])
+		i, seen := first[hash]
+		if !seen {
+			chunk.Metadata["content_hash"] = hash
+			first[hash] = len(kept)
+			kept = append(kept, chunk)
+			continue
+		}
+		original := kept[i].Metadata
+		if _, ok := original["duplicate_ids"]; !ok {
+			original["duplicate_ids"] = []string{}
+			original["source_locations"] = []string{extract.ChunkLocation(original)}
+		}
+		original["duplicate_ids"] = append(original["duplicate_ids"].([]string), chunk.ID)
+		original["source_locations"] = append(original["source_locations"].([]string), extract.ChunkLocation(chunk.Metadata))
+	}
+	return kept, len(chunks) - len(kept)
+}
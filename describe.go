@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// runDescribe implements the "describe" subcommand:
+// describe [-project dir] <package-pattern> <Name|Type.Method>.
+func runDescribe(args []string) (*extract.SymbolDescription, error) {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	projectFlag := fs.String("project", ".", "Root directory of the Go module or workspace")
+	buildTags := fs.String("tags", "", "Comma-separated build tags to load packages with")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return nil, fmt.Errorf("usage: describe [-project dir] <package-pattern> <Name|Type.Method>")
+	}
+	projectPath, err := extract.ValidateProjectPath(*projectFlag)
+	if err != nil {
+		return nil, err
+	}
+	opts := extract.DefaultExtractOptions()
+	opts.BuildTags = splitList(*buildTags)
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	describer := &extract.Describer{ProjectPath: projectPath, Options: opts}
+	return describer.DescribeSymbol(context.Background(), fs.Arg(0), fs.Arg(1))
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+const (
+	// formatBulk writes Elasticsearch/OpenSearch _bulk NDJSON: an index action and the
+	// document (see bulkDocument) per chunk, for POST /<index>/_bulk.
+	formatBulk = "bulk"
+	// defaultESIndex is the index chunks are indexed into.
+	defaultESIndex = "go_code_chunks"
+	// esAPIKeyEnv names the environment variable holding the Elasticsearch API key.
+	esAPIKeyEnv = "ELASTICSEARCH_API_KEY"
+)
+
+// bulkDocument is the indexed form of a chunk: the chunk ID and text, the ChunkMetadata
+// core fields as top-level fields, the remaining metadata in metadata_extra (stored but
+// not indexed) and the embedding, if any.
+func bulkDocument(chunk extract.ChromaDocument) map[string]interface{} {
+	doc := map[string]interface{}{"chunk_id": chunk.ID, "document": chunk.Document}
+	extra := make(map[string]interface{})
+	for key, value := range chunk.Metadata {
+		switch {
+		case value == nil:
+		case extract.IsCoreMetadataKey(key):
+			doc[key] = value
+		default:
+			extra[key] = value
+		}
+	}
+	if len(extra) > 0 {
+		doc["metadata_extra"] = extra
+	}
+	if len(chunk.Embedding) > 0 {
+		doc["embedding"] = chunk.Embedding
+	}
+	return doc
+}
+
+// encodeBulkChunk returns the action and document lines of a chunk. The action names no
+// index, so the file is posted to /<index>/_bulk.
+func encodeBulkChunk(chunk extract.ChromaDocument) ([]byte, error) {
+	action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_id": chunk.ID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chunk %s: %w", chunk.ID, err)
+	}
+	doc, err := json.Marshal(bulkDocument(chunk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chunk %s: %w", chunk.ID, err)
+	}
+	line := append(action, '\n')
+	line = append(line, doc...)
+	return append(line, '\n'), nil
+}
+
+// esMappings maps the chunk ID and metadata core fields to keyword, integer and boolean
+// fields, the chunk text, signature and qualified name to full-text fields and the
+// embedding of the given size to a vector field of the server's distribution.
+func esMappings(dimensions int, openSearch bool) map[string]interface{} {
+	properties := map[string]interface{}{
+		"chunk_id":       map[string]interface{}{"type": "keyword"},
+		"document":       map[string]interface{}{"type": "text"},
+		"metadata_extra": map[string]interface{}{"type": "object", "enabled": false},
+	}
+	t := reflect.TypeOf(extract.ChunkMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		switch t.Field(i).Type.Kind() {
+		case reflect.Int:
+			properties[name] = map[string]interface{}{"type": "integer"}
+		case reflect.Bool:
+			properties[name] = map[string]interface{}{"type": "boolean"}
+		default:
+			properties[name] = map[string]interface{}{"type": "keyword"}
+		}
+	}
+	for _, name := range []string{"signature", "qualified_name"} {
+		properties[name] = map[string]interface{}{
+			"type": "text", "fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 1024}},
+		}
+	}
+	switch {
+	case dimensions == 0:
+	case openSearch:
+		properties["embedding"] = map[string]interface{}{"type": "knn_vector", "dimension": dimensions}
+	default:
+		properties["embedding"] = map[string]interface{}{"type": "dense_vector", "dims": dimensions, "index": true, "similarity": "cosine"}
+	}
+	return map[string]interface{}{"properties": properties}
+}
+
+// esClient talks to the REST API of an Elasticsearch or OpenSearch server. Basic auth
+// credentials may be given in the URL.
+type esClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// do sends body (JSON, or NDJSON when it is a []byte) and decodes the response into
+// result, if not nil. It returns the response status code.
+func (c *esClient) do(ctx context.Context, method, path string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+		contentType = "application/x-ndjson"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach Elasticsearch: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read Elasticsearch response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("Elasticsearch returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(respBody)))
+	}
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode Elasticsearch response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// isOpenSearch reports whether the server is OpenSearch rather than Elasticsearch.
+func (c *esClient) isOpenSearch(ctx context.Context) (bool, error) {
+	var info struct {
+		Version struct {
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/", nil, &info); err != nil {
+		return false, err
+	}
+	return info.Version.Distribution == "opensearch", nil
+}
+
+// ensureIndex creates the index with esMappings unless it exists.
+func (c *esClient) ensureIndex(ctx context.Context, index string, dimensions int) error {
+	status, err := c.do(ctx, http.MethodHead, "/"+url.PathEscape(index), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return fmt.Errorf("failed to look up index %s: %w", index, err)
+	}
+	openSearch, err := c.isOpenSearch(ctx)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"mappings": esMappings(dimensions, openSearch)}
+	if openSearch && dimensions > 0 {
+		body["settings"] = map[string]interface{}{"index": map[string]interface{}{"knn": true}}
+	}
+	if _, err := c.do(ctx, http.MethodPut, "/"+url.PathEscape(index), body, nil); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", index, err)
+	}
+	return nil
+}
+
+// bulk posts NDJSON actions to the index and reports the items the server rejected.
+func (c *esClient) bulk(ctx context.Context, index string, data []byte) error {
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_bulk", data, &response); err != nil {
+		return err
+	}
+	if !response.Errors {
+		return nil
+	}
+	var failed []string
+	for _, item := range response.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				failed = append(failed, result.ID+": "+result.Error.Type+": "+result.Error.Reason)
+			}
+		}
+	}
+	return fmt.Errorf("%d chunks were rejected: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// esSink indexes chunks into an Elasticsearch or OpenSearch index with _bulk requests of
+// batchSize chunks, replacing documents with the same IDs. The index is created on the
+// first flush, with a vector field of the first embedding's size. Chunks flagged
+// embedding_skipped are left out.
+type esSink struct {
+	client    *esClient
+	index     string
+	batchSize int
+	pending   []extract.ChromaDocument
+	created   bool
+	batches   int
+}
+
+// newESSink returns a sink for the server at baseURL. The API key defaults to
+// $ELASTICSEARCH_API_KEY.
+func newESSink(baseURL, apiKey, index string, batchSize int) (*esSink, error) {
+	if index == "" || index != strings.ToLower(index) || strings.ContainsAny(index, `\/*?"<>| ,#:`) {
+		return nil, fmt.Errorf("invalid Elasticsearch index %q: must be lower case without spaces or any of \\/*?\"<>|,#:", index)
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv(esAPIKeyEnv)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultChromaBatchSize
+	}
+	client := &esClient{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, http: &http.Client{Timeout: 2 * time.Minute}}
+	return &esSink{client: client, index: index, batchSize: batchSize}, nil
+}
+
+func (s *esSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	for _, chunk := range chunks {
+		if skipped, _ := chunk.Metadata["embedding_skipped"].(bool); skipped {
+			continue
+		}
+		s.pending = append(s.pending, chunk)
+		if len(s.pending) == s.batchSize {
+			if err := s.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *esSink) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if !s.created {
+		if err := s.client.ensureIndex(ctx, s.index, len(s.pending[0].Embedding)); err != nil {
+			return err
+		}
+		s.created = true
+	}
+	s.batches++
+	var data []byte
+	for _, chunk := range s.pending {
+		lines, err := encodeBulkChunk(chunk)
+		if err != nil {
+			return err
+		}
+		data = append(data, lines...)
+	}
+	if err := s.client.bulk(ctx, s.index, data); err != nil {
+		return fmt.Errorf("failed to index batch %d (%d chunks from %s): %w", s.batches, len(s.pending), s.pending[0].ID, err)
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+func (s *esSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// redactURL masks the password of a URL with basic auth credentials.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// String describes the destination in messages.
+func (s *esSink) String() string {
+	return fmt.Sprintf("Elasticsearch index %s at %s", s.index, redactURL(s.client.baseURL))
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// EmbeddingProvider computes the embeddings of texts, returned in the same order. New
+// providers implement it and register a constructor with RegisterEmbeddingProvider.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingOptions select and configure an embedding provider.
+type EmbeddingOptions struct {
+	Provider string
+	Model    string
+	// URL is the provider's base URL; empty means the provider's default.
+	URL    string
+	APIKey string
+	// Dimensions is the embedding size to request; 0 keeps the model's default.
+	Dimensions int
+	BatchSize  int
+	// Concurrency is the number of requests in flight. RequestsPerSecond and
+	// TokensPerMinute (estimated input tokens) cap the request rate; 0 means no limit.
+	// Failed requests are retried up to MaxRetries times.
+	Concurrency       int
+	RequestsPerSecond float64
+	TokensPerMinute   int
+	MaxRetries        int
+}
+
+// defaultEmbeddingBatchSize is how many chunks are embedded per request.
+const defaultEmbeddingBatchSize = 64
+
+var (
+	embeddingProvidersMu sync.Mutex
+	embeddingProviders   = map[string]func(EmbeddingOptions) (EmbeddingProvider, error){
+		embeddingProviderOpenAI: newOpenAIEmbedder,
+		embeddingProviderOllama: newOllamaEmbedder,
+	}
+)
+
+// RegisterEmbeddingProvider makes a provider available under name (-embed-provider).
+func RegisterEmbeddingProvider(name string, newProvider func(EmbeddingOptions) (EmbeddingProvider, error)) {
+	embeddingProvidersMu.Lock()
+	defer embeddingProvidersMu.Unlock()
+	embeddingProviders[name] = newProvider
+}
+
+// embeddingProviderNames returns the registered provider names, sorted.
+func embeddingProviderNames() []string {
+	embeddingProvidersMu.Lock()
+	defer embeddingProvidersMu.Unlock()
+	names := make([]string, 0, len(embeddingProviders))
+	for name := range embeddingProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newEmbeddingProvider constructs the provider selected by opts.
+func newEmbeddingProvider(opts EmbeddingOptions) (EmbeddingProvider, error) {
+	embeddingProvidersMu.Lock()
+	newProvider, ok := embeddingProviders[opts.Provider]
+	embeddingProvidersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q (want one of %s)", opts.Provider, strings.Join(embeddingProviderNames(), ", "))
+	}
+	if opts.Model == "" {
+		return nil, fmt.Errorf("embedding provider %s needs a model", opts.Provider)
+	}
+	return newProvider(opts)
+}
+
+const (
+	// embeddingProviderOpenAI uses the OpenAI API or any server implementing its
+	// /embeddings endpoint (vLLM, LocalAI, Azure gateways, ...).
+	embeddingProviderOpenAI = "openai"
+	defaultOpenAIURL        = "https://api.openai.com/v1"
+	// embeddingAPIKeyEnv names the environment variable holding the OpenAI API key.
+	embeddingAPIKeyEnv = "OPENAI_API_KEY"
+)
+
+// openAIEmbedder computes embeddings with an OpenAI-compatible /embeddings endpoint.
+type openAIEmbedder struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	dimensions int // 0 keeps the model's default.
+	http       *http.Client
+}
+
+// newOpenAIEmbedder returns an embedder for opts.Model, using $OPENAI_API_KEY when
+// opts.APIKey is empty.
+func newOpenAIEmbedder(opts EmbeddingOptions) (EmbeddingProvider, error) {
+	if opts.URL == "" {
+		opts.URL = defaultOpenAIURL
+	}
+	if opts.APIKey == "" {
+		opts.APIKey = os.Getenv(embeddingAPIKeyEnv)
+	}
+	return &openAIEmbedder{
+		baseURL:    strings.TrimRight(opts.URL, "/"),
+		apiKey:     opts.APIKey,
+		model:      opts.Model,
+		dimensions: opts.Dimensions,
+		http:       &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// Embed returns the embeddings of texts, in order.
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	request := map[string]interface{}{"model": e.model, "input": texts, "encoding_format": "float"}
+	if e.dimensions > 0 {
+		request["dimensions"] = e.dimensions
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the embedding API: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, newEmbeddingHTTPError("embedding API", resp, strings.TrimSpace(string(body)))
+	}
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding API returned %d embeddings for %d inputs", len(response.Data), len(texts))
+	}
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Index < response.Data[j].Index })
+	embeddings := make([][]float32, len(texts))
+	for i, item := range response.Data {
+		embeddings[i] = item.Embedding
+	}
+	return embeddings, nil
+}
+
+// embeddingSink sets the Embedding of the chunks written to it, batchSize chunks per
+// request with up to concurrency requests in flight, and passes them on to next in the
+// order they were written. Chunks flagged embedding_skipped pass through without an
+// embedding.
+type embeddingSink struct {
+	next        extract.Sink
+	embedder    EmbeddingProvider
+	batchSize   int
+	concurrency int
+	pending     []extract.ChromaDocument
+}
+
+func (s *embeddingSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	for _, chunk := range chunks {
+		s.pending = append(s.pending, chunk)
+		if len(s.pending) >= s.batchSize*s.concurrency {
+			if err := s.embedPending(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// embedPending embeds the pending chunks, one request per batch, all batches at once,
+// and writes them to next.
+func (s *embeddingSink) embedPending(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 0, s.concurrency)
+	var errsMu sync.Mutex
+	for start := 0; start < len(s.pending); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(s.pending) {
+			end = len(s.pending)
+		}
+		wg.Add(1)
+		go func(batch []extract.ChromaDocument) {
+			defer wg.Done()
+			if err := s.embedBatch(ctx, batch); err != nil {
+				errsMu.Lock()
+				errs = append(errs, err)
+				errsMu.Unlock()
+			}
+		}(s.pending[start:end])
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	err := s.next.Write(ctx, s.pending)
+	s.pending = nil
+	return err
+}
+
+// embedBatch sets the embeddings of batch with one request.
+func (s *embeddingSink) embedBatch(ctx context.Context, batch []extract.ChromaDocument) error {
+	var texts []string
+	var indexes []int
+	for i, chunk := range batch {
+		if skipped, _ := chunk.Metadata["embedding_skipped"].(bool); skipped {
+			continue
+		}
+		texts = append(texts, chunk.Document)
+		indexes = append(indexes, i)
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	embeddings, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks %s..%s: %w", batch[0].ID, batch[len(batch)-1].ID, err)
+	}
+	if len(embeddings) != len(texts) {
+		return fmt.Errorf("embedding provider returned %d embeddings for %d chunks", len(embeddings), len(texts))
+	}
+	for i, embedding := range embeddings {
+		batch[indexes[i]].Embedding = embedding
+	}
+	return nil
+}
+
+func (s *embeddingSink) Flush(ctx context.Context) error {
+	if err := s.embedPending(ctx); err != nil {
+		return err
+	}
+	return s.next.Flush(ctx)
+}
+
+// Abort drops the chunks waiting for embeddings and aborts the next sink.
+func (s *embeddingSink) Abort() error {
+	s.pending = nil
+	return extract.AbortSink(s.next)
+}
+
+func (s *embeddingSink) Close() error {
+	err := s.embedPending(context.Background())
+	if cache, ok := s.embedder.(*embeddingCache); ok {
+		log.Print(cache.summary())
+	}
+	if closeErr := s.next.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// defaultEmbeddingCacheDir is where embeddings are cached between runs.
+const defaultEmbeddingCacheDir = ".chroma-extract-embeddings"
+
+// embeddingCache wraps a provider with an on-disk cache, so unchanged chunks are not
+// re-embedded. Entries are keyed by the SHA-256 of the provider, model, dimensions and
+// text, and stored as little-endian float32s in dir/<first two hex digits>/<hash>,
+// encrypted when key is set.
+type embeddingCache struct {
+	provider EmbeddingProvider
+	dir      string
+	prefix   string // Hashed before the text: provider, model and dimensions.
+	key      []byte
+
+	mu           sync.Mutex
+	hits, misses int
+}
+
+func newEmbeddingCache(provider EmbeddingProvider, dir string, opts EmbeddingOptions, key []byte) *embeddingCache {
+	prefix := opts.Provider + "\x00" + opts.Model + "\x00" + strconv.Itoa(opts.Dimensions) + "\x00"
+	return &embeddingCache{provider: provider, dir: dir, prefix: prefix, key: key}
+}
+
+// Embed returns cached embeddings and computes the missing ones with the provider.
+func (c *embeddingCache) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	var missing []string
+	var indexes []int
+	for i, text := range texts {
+		if embedding, ok := c.load(c.path(text)); ok {
+			embeddings[i] = embedding
+			continue
+		}
+		missing = append(missing, text)
+		indexes = append(indexes, i)
+	}
+	c.mu.Lock()
+	c.hits += len(texts) - len(missing)
+	c.misses += len(missing)
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+	computed, err := c.provider.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(computed) != len(missing) {
+		return nil, fmt.Errorf("embedding provider returned %d embeddings for %d chunks", len(computed), len(missing))
+	}
+	for i, embedding := range computed {
+		embeddings[indexes[i]] = embedding
+		if err := c.store(c.path(missing[i]), embedding); err != nil {
+			log.Printf("Warning: failed to cache embedding: %v", err)
+		}
+	}
+	return embeddings, nil
+}
+
+// summary describes the cache use, for the log.
+func (c *embeddingCache) summary() string {
+	return fmt.Sprintf("Embedding cache: %d hits, %d misses (%s)", c.hits, c.misses, c.dir)
+}
+
+func (c *embeddingCache) path(text string) string {
+	sum := sha256.Sum256([]byte(c.prefix + text))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// load reads a cache entry; unreadable entries count as missing.
+func (c *embeddingCache) load(path string) ([]float32, bool) {
+	data, err := readArtifact(path, c.key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: ignoring cached embedding %s: %v", path, err)
+		}
+		return nil, false
+	}
+	if len(data) == 0 || len(data)%4 != 0 {
+		log.Printf("Warning: ignoring corrupt cached embedding %s", path)
+		return nil, false
+	}
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return embedding, true
+}
+
+func (c *embeddingCache) store(path string, embedding []float32) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data := make([]byte, 4*len(embedding))
+	for i, f := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(f))
+	}
+	return writeArtifact(path, data, c.key)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+const (
+	defaultEmbeddingConcurrency = 4
+	defaultEmbeddingRetries     = 5
+	defaultEmbeddingBackoff     = 2 * time.Second
+	maxEmbeddingBackoff         = time.Minute
+)
+
+// embeddingHTTPError is a response from an embedding API with a non-2xx status.
+type embeddingHTTPError struct {
+	Service    string
+	Status     string
+	StatusCode int
+	Body       string
+	// RetryAfter is the wait the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *embeddingHTTPError) Error() string {
+	return e.Service + " returned " + e.Status + ": " + e.Body
+}
+
+func newEmbeddingHTTPError(service string, resp *http.Response, body string) *embeddingHTTPError {
+	err := &embeddingHTTPError{Service: service, Status: resp.Status, StatusCode: resp.StatusCode, Body: body}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+// rateLimiter is a token bucket refilled at rate per second up to burst. Waits reserve
+// their tokens up front, so concurrent callers are served in arrival order.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	available float64
+	last      time.Time
+}
+
+// newRateLimiter returns a full bucket, or nil (no limit) when rate is not positive.
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, burst: burst, available: burst, last: time.Now()}
+}
+
+// wait blocks until n tokens are available.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.available += now.Sub(l.last).Seconds() * l.rate
+	if l.available > l.burst {
+		l.available = l.burst
+	}
+	l.last = now
+	l.available -= float64(n)
+	delay := time.Duration(-l.available / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedEmbedder keeps a provider within a request rate and an estimated input token
+// rate, and retries requests failing with a connection error, a timeout or a 429 or 5xx
+// response with exponential backoff (or as long as the server's Retry-After asks).
+type limitedEmbedder struct {
+	provider EmbeddingProvider
+	requests *rateLimiter
+	tokens   *rateLimiter
+	retries  int
+	backoff  time.Duration
+}
+
+func newLimitedEmbedder(provider EmbeddingProvider, opts EmbeddingOptions) *limitedEmbedder {
+	return &limitedEmbedder{
+		provider: provider,
+		requests: newRateLimiter(opts.RequestsPerSecond, 1),
+		tokens:   newRateLimiter(float64(opts.TokensPerMinute)/60, float64(opts.TokensPerMinute)),
+		retries:  opts.MaxRetries,
+		backoff:  defaultEmbeddingBackoff,
+	}
+}
+
+func (e *limitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	tokens := 0
+	for _, text := range texts {
+		tokens += extract.EstimateTokens(text)
+	}
+	delay := e.backoff
+	for attempt := 1; ; attempt++ {
+		if err := e.requests.wait(ctx, 1); err != nil {
+			return nil, err
+		}
+		if err := e.tokens.wait(ctx, tokens); err != nil {
+			return nil, err
+		}
+		embeddings, err := e.provider.Embed(ctx, texts)
+		if err == nil || attempt > e.retries || ctx.Err() != nil || !retryableEmbeddingError(err) {
+			return embeddings, err
+		}
+		wait := delay
+		var status *embeddingHTTPError
+		if errors.As(err, &status) && status.RetryAfter > wait {
+			wait = status.RetryAfter
+		}
+		log.Printf("Warning: %v; retrying in %s (retry %d of %d)", err, wait, attempt, e.retries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+		if delay *= 2; delay > maxEmbeddingBackoff {
+			delay = maxEmbeddingBackoff
+		}
+	}
+}
+
+// retryableEmbeddingError reports whether err is a rate limit, server or network error.
+func retryableEmbeddingError(err error) bool {
+	var status *embeddingHTTPError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// Chunk event types streamed to watch-mode subscribers.
+const (
+	chunkEventAdd    = "add"
+	chunkEventUpdate = "update"
+	chunkEventDelete = "delete"
+)
+
+// ChunkEvent is one change between two published generations. Chunk is omitted for
+// deletions.
+type ChunkEvent struct {
+	Type       string                  `json:"type"`
+	Generation int                     `json:"generation"`
+	ID         string                  `json:"id"`
+	Chunk      *extract.ChromaDocument `json:"chunk,omitempty"`
+}
+
+// subscriberBuffer is the number of unsent generations a subscriber may fall behind
+// before it is disconnected and must resynchronize from the output file.
+const subscriberBuffer = 16
+
+// eventBroker fans generation diffs out to server-sent-events subscribers.
+type eventBroker struct {
+	mu          sync.Mutex
+	generation  int
+	subscribers map[chan []ChunkEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan []ChunkEvent]struct{})}
+}
+
+func (b *eventBroker) subscribe() (chan []ChunkEvent, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan []ChunkEvent, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	return ch, b.generation
+}
+
+func (b *eventBroker) unsubscribe(ch chan []ChunkEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish delivers the events of one generation to every subscriber. A subscriber whose
+// buffer is full is dropped rather than allowed to stall the watcher.
+func (b *eventBroker) publish(generation int, events []ChunkEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.generation = generation
+	for ch := range b.subscribers {
+		select {
+		case ch <- events:
+		default:
+			log.Printf("Warning: dropping slow event subscriber at generation %d.", generation)
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// diffSnapshots returns the add, update and delete events that turn prev into next,
+// ordered by chunk ID. A nil prev yields an add event for every chunk.
+func diffSnapshots(prev, next *chunkSnapshot) []ChunkEvent {
+	var events []ChunkEvent
+	for i := range next.Chunks {
+		chunk := &next.Chunks[i]
+		eventType := chunkEventAdd
+		if prev != nil {
+			if oldFingerprint, ok := prev.Fingerprints[chunk.ID]; ok {
+				if oldFingerprint == next.Fingerprints[chunk.ID] {
+					continue
+				}
+				eventType = chunkEventUpdate
+			}
+		}
+		events = append(events, ChunkEvent{Type: eventType, Generation: next.Generation, ID: chunk.ID, Chunk: chunk})
+	}
+	if prev != nil {
+		for id := range prev.Fingerprints {
+			if _, ok := next.Fingerprints[id]; !ok {
+				events = append(events, ChunkEvent{Type: chunkEventDelete, Generation: next.Generation, ID: id})
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// snapshotFingerprints maps each chunk ID to its content fingerprint.
+func snapshotFingerprints(chunks []extract.ChromaDocument) (map[string]string, error) {
+	fingerprints := make(map[string]string, len(chunks))
+	for _, chunk := range chunks {
+		fingerprint, err := chunkFingerprint(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fingerprint chunk %s: %w", chunk.ID, err)
+		}
+		fingerprints[chunk.ID] = fingerprint
+	}
+	return fingerprints, nil
+}
+
+// serveEvents streams chunk events as server-sent events: one "add", "update" or
+// "delete" event per changed chunk, followed by a "generation" event once a generation
+// has been fully delivered. A "hello" event carries the generation current at connect
+// time; subscribers should load that generation from the output file.
+func (b *eventBroker) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	ch, generation := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	writeServerSentEvent(w, "hello", map[string]int{"generation": generation})
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case events, open := <-ch:
+			if !open {
+				return // Dropped as a slow subscriber.
+			}
+			for _, event := range events {
+				writeServerSentEvent(w, event.Type, event)
+			}
+			if len(events) > 0 {
+				writeServerSentEvent(w, "generation", map[string]int{"generation": events[0].Generation})
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Warning: could not encode %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// listenEvents starts the event stream server on addr. Listening happens synchronously
+// so that an unusable address is reported before watching starts.
+func (b *eventBroker) listenEvents(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for event subscribers: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", b.serveEvents)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Event stream server stopped: %v", err)
+		}
+	}()
+	log.Printf("Streaming chunk events on http://%s/events.", listener.Addr())
+	return nil
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// setBenchmarkMetadata marks a benchmark chunk with entity_type "benchmark" and the
+// symbol it is named after as "benchmarked_symbol" (see testedSymbol).
+func setBenchmarkMetadata(metadata map[string]interface{}, funcDecl *ast.FuncDecl, importPath string) {
+	metadata["entity_type"] = "benchmark"
+	symbol, _ := testedSymbol(strings.TrimPrefix(funcDecl.Name.Name, "Benchmark"))
+	metadata["benchmarked_symbol"] = testedSymbolPath(importPath, symbol)
+}
+
+// setFuzzMetadata marks a fuzz test chunk with entity_type "fuzz", the symbol it is named
+// after as "fuzzed_symbol", the argument types of its fuzz target (the function passed
+// to f.Fuzz, after its *testing.T) as "fuzz_arg_types" and the number of f.Add calls as
+// "seed_corpus_size".
+func setFuzzMetadata(metadata map[string]interface{}, funcDecl *ast.FuncDecl, importPath string, info *types.Info) {
+	metadata["entity_type"] = "fuzz"
+	symbol, _ := testedSymbol(strings.TrimPrefix(funcDecl.Name.Name, "Fuzz"))
+	metadata["fuzzed_symbol"] = testedSymbolPath(importPath, symbol)
+
+	param := funcDecl.Type.Params.List[0]
+	if len(param.Names) == 0 || funcDecl.Body == nil {
+		return
+	}
+	f := param.Names[0].Name
+	seeds := 0
+	var argTypes []string
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		call, isCall := n.(*ast.CallExpr)
+		if !isCall {
+			return true
+		}
+		sel, isSel := call.Fun.(*ast.SelectorExpr)
+		if !isSel {
+			return true
+		}
+		if ident, isIdent := sel.X.(*ast.Ident); !isIdent || ident.Name != f {
+			return true
+		}
+		switch sel.Sel.Name {
+		case "Add":
+			seeds++
+		case "Fuzz":
+			if len(call.Args) == 1 {
+				if target, isLit := call.Args[0].(*ast.FuncLit); isLit {
+					argTypes = fuzzArgTypes(target.Type, info)
+				}
+			}
+		}
+		return true
+	})
+	if len(argTypes) > 0 {
+		metadata["fuzz_arg_types"] = argTypes
+	}
+	metadata["seed_corpus_size"] = seeds
+}
+
+// fuzzArgTypes lists the parameter types of a fuzz target after its *testing.T.
+func fuzzArgTypes(target *ast.FuncType, info *types.Info) []string {
+	var argTypes []string
+	for _, field := range target.Params.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			argTypes = append(argTypes, getTypeString(field.Type, info))
+		}
+	}
+	if len(argTypes) == 0 {
+		return nil
+	}
+	return argTypes[1:]
+}
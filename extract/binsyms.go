@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// binarySymbol aggregates the text symbols of one source function in a built binary.
+// Generic functions contribute one symbol per shape instantiation.
+type binarySymbol struct {
+	size      int
+	instances int
+}
+
+// readBinarySymbols lists the function symbols of a Go binary with "go tool nm" and
+// returns them keyed by linker name with type arguments stripped. mainPath is the import
+// path of the binary's main package, which the linker names "main".
+func readBinarySymbols(binaryPath string) (symbols map[string]*binarySymbol, mainPath string, err error) {
+	if info, err := buildinfo.ReadFile(binaryPath); err == nil {
+		mainPath = info.Path
+	}
+	output, err := exec.Command("go", "tool", "nm", "-size", binaryPath).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, "", fmt.Errorf("go tool nm failed: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, "", fmt.Errorf("failed to run go tool nm: %w", err)
+	}
+
+	symbols = make(map[string]*binarySymbol)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Format: address size type name
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || (fields[2] != "T" && fields[2] != "t") {
+			continue
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		name := stripTypeArgs(strings.Join(fields[3:], " "))
+		sym := symbols[name]
+		if sym == nil {
+			sym = &binarySymbol{}
+			symbols[name] = sym
+		}
+		sym.size += size
+		sym.instances++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read go tool nm output: %w", err)
+	}
+	return symbols, mainPath, nil
+}
+
+// linkerSymbolName converts a types.Func.FullName ("pkg.F", "(*pkg.T).M", "(pkg.T).M")
+// into the linker's naming ("pkg.F", "pkg.(*T).M", "pkg.T.M"), with type arguments
+// stripped and mainPath renamed to "main".
+func linkerSymbolName(qualifiedName, mainPath string) string {
+	name := stripTypeArgs(qualifiedName)
+	if strings.HasPrefix(name, "(") {
+		// (*pkg/path.T).M -> pkg/path.(*T).M and (pkg/path.T).M -> pkg/path.T.M
+		closing := strings.Index(name, ")")
+		if closing < 0 {
+			return name
+		}
+		receiver := name[1:closing]
+		pointer := strings.HasPrefix(receiver, "*")
+		receiver = strings.TrimPrefix(receiver, "*")
+		dot := strings.LastIndex(receiver, ".")
+		if dot < 0 {
+			return name
+		}
+		typeName := receiver[dot+1:]
+		if pointer {
+			typeName = "(*" + typeName + ")"
+		}
+		name = receiver[:dot] + "." + typeName + name[closing+1:]
+	}
+	if mainPath != "" && strings.HasPrefix(name, mainPath+".") {
+		name = "main" + name[len(mainPath):]
+	}
+	return name
+}
+
+// stripTypeArgs removes every bracketed type argument or parameter list from name.
+func stripTypeArgs(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// binaryIndex is the symbol table of a built binary, read once and applied to the chunks
+// of each package as it is finished.
+type binaryIndex struct {
+	symbols  map[string]*binarySymbol
+	mainPath string
+}
+
+func loadBinaryIndex(binaryPath string) (*binaryIndex, error) {
+	symbols, mainPath, err := readBinarySymbols(binaryPath)
+	if err != nil {
+		return nil, err
+	}
+	return &binaryIndex{symbols: symbols, mainPath: mainPath}, nil
+}
+
+// annotate records for every function and method chunk whether it is compiled into the
+// binary ("in_binary") and the machine code size of its symbols ("binary_size",
+// "binary_instances" for generic instantiations), and returns how many are present.
+// Functions missing from the binary were either eliminated as dead code or inlined at
+// every call site.
+func (b *binaryIndex) annotate(chunks []ChromaDocument) int {
+	present := 0
+	for i := range chunks {
+		entityType, _ := chunks[i].Metadata["entity_type"].(string)
+		qualified, _ := chunks[i].Metadata["qualified_name"].(string)
+		if (entityType != "function" && entityType != "method") || qualified == "" {
+			continue
+		}
+		sym, ok := b.symbols[linkerSymbolName(qualified, b.mainPath)]
+		chunks[i].Metadata["in_binary"] = ok
+		if ok {
+			chunks[i].Metadata["binary_size"] = sym.size
+			chunks[i].Metadata["binary_instances"] = sym.instances
+			present++
+		}
+	}
+	return present
+}
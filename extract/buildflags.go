@@ -0,0 +1,27 @@
+package extract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// packageBuildFlags returns the go build flags packages.Load runs go list with, so
+// files guarded by build constraints on BuildTags are part of the loaded packages.
+func packageBuildFlags(opts ExtractOptions) []string {
+	if len(opts.BuildTags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(opts.BuildTags, ",")}
+}
+
+// validateBuildTags rejects tags go build would not accept.
+func validateBuildTags(tags []string) error {
+	for _, tag := range tags {
+		for _, r := range tag {
+			if !(r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return fmt.Errorf("invalid build tag %q", tag)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package extract
+
+// retrievalBundles groups the chunks of one package by type so that each method chunk
+// can point at its type and sibling methods, letting clients fetch a coherent bundle
+// (type plus method set) in one round trip.
+type retrievalBundles struct {
+	typeChunks   map[string]int   // type name -> chunk index
+	methodChunks map[string][]int // receiver base type name -> chunk indexes, in source order
+}
+
+func newRetrievalBundles() *retrievalBundles {
+	return &retrievalBundles{
+		typeChunks:   make(map[string]int),
+		methodChunks: make(map[string][]int),
+	}
+}
+
+func (b *retrievalBundles) addType(name string, chunkIndex int) {
+	b.typeChunks[name] = chunkIndex
+}
+
+func (b *retrievalBundles) addMethod(receiverTypeName string, chunkIndex int) {
+	if receiverTypeName == "" {
+		return
+	}
+	b.methodChunks[receiverTypeName] = append(b.methodChunks[receiverTypeName], chunkIndex)
+}
+
+// attach sets "bundle_type_id" and "bundle_method_ids" on each method chunk (its type and
+// its sibling methods) and "bundle_method_ids" on each type chunk (all of its methods).
+func (b *retrievalBundles) attach(chunks []ChromaDocument) {
+	for typeName, methodIndexes := range b.methodChunks {
+		methodIDs := make([]string, len(methodIndexes))
+		for i, idx := range methodIndexes {
+			methodIDs[i] = chunks[idx].ID
+		}
+
+		typeIndex, hasType := b.typeChunks[typeName]
+		if hasType {
+			chunks[typeIndex].Metadata["bundle_method_ids"] = methodIDs
+		}
+
+		for i, idx := range methodIndexes {
+			if hasType {
+				chunks[idx].Metadata["bundle_type_id"] = chunks[typeIndex].ID
+			}
+			siblings := make([]string, 0, len(methodIDs)-1)
+			siblings = append(siblings, methodIDs[:i]...)
+			siblings = append(siblings, methodIDs[i+1:]...)
+			chunks[idx].Metadata["bundle_method_ids"] = siblings
+		}
+	}
+}
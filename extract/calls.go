@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// CallEdge is one outgoing call from a function or method chunk. For calls into generic
+// code, Callee is always the uninstantiated origin (so edges to Map[int] and Map[string]
+// collapse onto one node) while Instantiation and TypeArgs record the concrete use.
+type CallEdge struct {
+	Callee        string   `json:"callee"`
+	Instantiation string   `json:"instantiation,omitempty"`
+	TypeArgs      []string `json:"type_args,omitempty"`
+}
+
+// collectCallEdges resolves every static call in node to its callee. Calls through
+// function values and builtins have no static callee and are skipped. Edges are
+// de-duplicated and sorted by callee, then instantiation.
+func collectCallEdges(node ast.Node, info *types.Info) []CallEdge {
+	if info == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var edges []CallEdge
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, isCall := n.(*ast.CallExpr)
+		if !isCall {
+			return true
+		}
+		ident := calleeIdent(call.Fun)
+		if ident == nil {
+			return true
+		}
+		fn, isFunc := info.Uses[ident].(*types.Func)
+		if !isFunc {
+			return true
+		}
+
+		origin := fn.Origin()
+		edge := CallEdge{Callee: origin.FullName()}
+
+		// Generic function instantiation, explicit (Map[int, string](...)) or inferred.
+		if instance, ok := info.Instances[ident]; ok && instance.TypeArgs != nil && instance.TypeArgs.Len() > 0 {
+			for i := 0; i < instance.TypeArgs.Len(); i++ {
+				edge.TypeArgs = append(edge.TypeArgs, instance.TypeArgs.At(i).String())
+			}
+			edge.Instantiation = edge.Callee + "[" + strings.Join(edge.TypeArgs, ",") + "]"
+		} else if fn != origin {
+			// Method of an instantiated generic type: the receiver carries the type arguments.
+			edge.Instantiation = fn.FullName()
+			if named := receiverOfFunc(fn); named != nil && named.TypeArgs() != nil {
+				for i := 0; i < named.TypeArgs().Len(); i++ {
+					edge.TypeArgs = append(edge.TypeArgs, named.TypeArgs().At(i).String())
+				}
+			}
+		}
+
+		key := edge.Callee + "|" + edge.Instantiation
+		if !seen[key] {
+			seen[key] = true
+			edges = append(edges, edge)
+		}
+		return true
+	})
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Callee != edges[j].Callee {
+			return edges[i].Callee < edges[j].Callee
+		}
+		return edges[i].Instantiation < edges[j].Instantiation
+	})
+	return edges
+}
+
+// calleeIdent returns the identifier naming the called function, looking through
+// parentheses, package/receiver selectors and explicit type-argument lists.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	for {
+		switch f := fun.(type) {
+		case *ast.ParenExpr:
+			fun = f.X
+		case *ast.IndexExpr:
+			fun = f.X
+		case *ast.IndexListExpr:
+			fun = f.X
+		case *ast.SelectorExpr:
+			return f.Sel
+		case *ast.Ident:
+			return f
+		default:
+			return nil
+		}
+	}
+}
+
+// receiverOfFunc returns the named receiver type of a method, or nil for plain functions.
+func receiverOfFunc(fn *types.Func) *types.Named {
+	sig, isSig := fn.Type().(*types.Signature)
+	if !isSig || sig.Recv() == nil {
+		return nil
+	}
+	return namedTypeOf(sig.Recv().Type())
+}
+
+// calleeNames returns the distinct callee origins of a set of edges, for flat metadata filters.
+func calleeNames(edges []CallEdge) []string {
+	var names []string
+	for i, edge := range edges {
+		if i == 0 || edges[i-1].Callee != edge.Callee {
+			names = append(names, edge.Callee)
+		}
+	}
+	return names
+}
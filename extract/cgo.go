@@ -0,0 +1,118 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// usesCgo reports whether pkg has files that import "C". The loader type-checks such
+// packages from the files cmd/cgo generates, which are not the source files and have
+// C.name rewritten.
+func usesCgo(pkg *packages.Package) bool {
+	_, ok := pkg.Imports["runtime/cgo"]
+	return ok && len(pkg.CompiledGoFiles) > 0 && !sameFiles(pkg.GoFiles, pkg.CompiledGoFiles)
+}
+
+// sameFiles reports whether two file lists are equal.
+func sameFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// typeCheckCgoSources parses the source files of a cgo package and type-checks them
+// against the loaded imports, with "C" as a fake package whose selectors are not
+// checked. It returns nil type information if that fails.
+func typeCheckCgoSources(fset *token.FileSet, pkg *packages.Package, invalidUTF8Policy string) ([]*ast.File, *types.Info) {
+	files := parsePackageFiles(fset, pkg, invalidUTF8Policy)
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+	conf := types.Config{
+		FakeImportC: true,
+		Sizes:       pkg.TypesSizes,
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imported, ok := pkg.Imports[path]; ok && imported.Types != nil {
+				return imported.Types, nil
+			}
+			return nil, fmt.Errorf("package %s is not loaded", path)
+		}),
+	}
+	if _, err := conf.Check(pkg.PkgPath, fset, files, info); err != nil {
+		log.Printf("Warning: type checking the cgo sources of %s failed: %v", pkg.ID, err)
+		return files, nil
+	}
+	return files, info
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// cgoPreamble returns the C preamble of a file that imports "C": the comment right above
+// the import. ok is false if the file does not import "C".
+func cgoPreamble(file *ast.File) (preamble *ast.CommentGroup, ok bool) {
+	for _, decl := range file.Decls {
+		genDecl, isGenDecl := decl.(*ast.GenDecl)
+		if !isGenDecl || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			if path, _ := strconv.Unquote(importSpec.Path.Value); path != "C" {
+				continue
+			}
+			if importSpec.Doc != nil {
+				return importSpec.Doc, true
+			}
+			return genDecl.Doc, true
+		}
+	}
+	return nil, false
+}
+
+// isCgoSelector reports whether sel refers to a C name (C.int, C.free).
+func isCgoSelector(sel *ast.SelectorExpr, info *types.Info) bool {
+	ident, isIdent := sel.X.(*ast.Ident)
+	if !isIdent || ident.Name != "C" {
+		return false
+	}
+	if info == nil {
+		return true
+	}
+	pkgName, isPkgName := info.Uses[ident].(*types.PkgName)
+	return isPkgName && pkgName.Imported().Path() == "C"
+}
+
+// setCgoSymbols records the C names a declaration refers to as "cgo_symbols".
+func setCgoSymbols(metadata map[string]interface{}, node ast.Node, info *types.Info) {
+	seen := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, isSel := n.(*ast.SelectorExpr); isSel && isCgoSelector(sel, info) {
+			seen["C."+sel.Sel.Name] = true
+		}
+		return true
+	})
+	if len(seen) > 0 {
+		metadata["cgo_symbols"] = sortedKeys(seen)
+	}
+}
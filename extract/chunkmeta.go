@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ChunkSchemaVersion is the version of the chunk metadata schema. It is recorded once
+// per output as "schema_version" (in the run manifest, and in the file metadata of
+// Parquet and protobuf output), not in every chunk. It is bumped whenever a field is
+// renamed, removed or changes its JSON type; adding fields does not change it.
+const ChunkSchemaVersion = 1
+
+// ChunkMetadata is the typed form of a chunk's metadata for Go consumers of the output.
+// The named fields are the core schema; every other field (call edges, quality flags,
+// binary symbols, ...) is kept in Extra. It marshals to the same flat JSON object as
+// ChromaDocument.Metadata. The extractor itself builds metadata as a map; ChunkMetadata
+// is how Go programs read it back (see TypedMetadata and DecodeChunks).
+//
+// EntityType is one of function, method, test, benchmark, fuzz, example, closure,
+// type_declaration, value_declaration, interface_method, embedded_asset, file,
+// package_summary or api_digest.
+type ChunkMetadata struct {
+	FilePath        string   `json:"file_path"`
+	PackageName     string   `json:"package_name"`
+	PackagePath     string   `json:"package_path,omitempty"`
+	EntityType      string   `json:"entity_type"`
+	EntityName      string   `json:"entity_name"`
+	QualifiedName   string   `json:"qualified_name,omitempty"`
+	StartLine       int      `json:"start_line,omitempty"`
+	EndLine         int      `json:"end_line,omitempty"`
+	Signature       string   `json:"signature,omitempty"`
+	ReceiverType    string   `json:"receiver_type,omitempty"`
+	DeclarationKind string   `json:"declaration_kind,omitempty"` // var, const or type
+	TypeCategory    string   `json:"type_category,omitempty"`    // struct, interface or alias_or_basic
+	Typed           bool     `json:"typed"`
+	IsInternal      bool     `json:"is_internal"`
+	Visibility      string   `json:"visibility,omitempty"`
+	License         string   `json:"license,omitempty"`
+	ModulePath      string   `json:"module_path,omitempty"`
+	ModuleVersion   string   `json:"module_version,omitempty"`
+	GoVersion       string   `json:"go_version,omitempty"`
+	Project         string   `json:"project,omitempty"`
+	Targets         []string `json:"targets,omitempty"`
+	Calls           []string `json:"calls,omitempty"`
+
+	Extra map[string]interface{} `json:"-"`
+}
+
+// IsCoreMetadataKey reports whether key is the JSON name of a named ChunkMetadata field.
+func IsCoreMetadataKey(key string) bool {
+	return coreMetadataKeys[key]
+}
+
+// chunkMetadataCore is ChunkMetadata without its JSON methods.
+type chunkMetadataCore ChunkMetadata
+
+// coreMetadataKeys are the JSON names of the named ChunkMetadata fields.
+var coreMetadataKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(ChunkMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// MarshalJSON flattens Extra into the object of named fields.
+func (m ChunkMetadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(chunkMetadataCore(m))
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Extra) == 0 {
+		return data, nil
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range m.Extra {
+		if coreMetadataKeys[key] {
+			return nil, fmt.Errorf("metadata field %q must not be set in Extra", key)
+		}
+		merged[key] = value
+	}
+	return json.Marshal(merged)
+}
+
+// UnmarshalJSON fills the named fields and collects all other fields in Extra.
+func (m *ChunkMetadata) UnmarshalJSON(data []byte) error {
+	var core chunkMetadataCore
+	if err := json.Unmarshal(data, &core); err != nil {
+		return err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	core.Extra = nil
+	for key, value := range all {
+		if coreMetadataKeys[key] {
+			continue
+		}
+		if core.Extra == nil {
+			core.Extra = make(map[string]interface{})
+		}
+		core.Extra[key] = value
+	}
+	*m = ChunkMetadata(core)
+	return nil
+}
+
+// TypedChunk is a chunk with typed metadata, as read back from the JSON output.
+type TypedChunk struct {
+	ID       string        `json:"id"`
+	Document string        `json:"document"`
+	Metadata ChunkMetadata `json:"metadata"`
+}
+
+// TypedMetadata converts the chunk's metadata to a ChunkMetadata.
+func (d ChromaDocument) TypedMetadata() (ChunkMetadata, error) {
+	var m ChunkMetadata
+	data, err := json.Marshal(d.Metadata)
+	if err != nil {
+		return m, fmt.Errorf("failed to marshal metadata of chunk %s: %w", d.ID, err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("metadata of chunk %s does not match the schema: %w", d.ID, err)
+	}
+	return m, nil
+}
+
+// DecodeChunks reads a JSON chunk file written by this tool. Its schema version is
+// recorded in the manifest of the run.
+func DecodeChunks(r io.Reader) ([]TypedChunk, error) {
+	var chunks []TypedChunk
+	if err := json.NewDecoder(r).Decode(&chunks); err != nil {
+		return nil, fmt.Errorf("failed to decode chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// ChunkLocation formats the file and lines of a chunk, e.g. pkg/util.go:12-20.
+func ChunkLocation(metadata map[string]interface{}) string {
+	location := fmt.Sprint(metadata["file_path"])
+	if start, ok := metadata["start_line"].(int); ok && start > 0 {
+		location += fmt.Sprintf(":%d", start)
+		if end, ok := metadata["end_line"].(int); ok && end > start {
+			location += fmt.Sprintf("-%d", end)
+		}
+	}
+	return location
+}
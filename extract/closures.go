@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// closure is a function literal found in a function, with its synthesized name.
+type closure struct {
+	Name string
+	Lit  *ast.FuncLit
+}
+
+// findClosures returns the function literals in node in source order, named after the
+// enclosing symbol and their position among the literals of the same enclosing function,
+// e.g. "Server.ServeHTTP$1" and, for one nested in it, "Server.ServeHTTP$1$1".
+func findClosures(node ast.Node, name string) []closure {
+	var closures []closure
+	n := 0
+	ast.Inspect(node, func(child ast.Node) bool {
+		lit, isLit := child.(*ast.FuncLit)
+		if !isLit {
+			return true
+		}
+		n++
+		litName := fmt.Sprintf("%s$%d", name, n)
+		closures = append(closures, closure{Name: litName, Lit: lit})
+		closures = append(closures, findClosures(lit.Body, litName)...)
+		return false
+	})
+	return closures
+}
+
+// closureChunks returns a child chunk for every closure in funcDecl of at least minTokens
+// estimated tokens, such as a handler or goroutine body buried in its parent function.
+// A child has entity_type "closure", a synthesized entity_name (see findClosures), the
+// parent's entity name as "parent_entity" and ID as "parent_id", and the ID
+// <parent ID>$<n>... matching its name.
+func closureChunks(parent ChromaDocument, funcDecl *ast.FuncDecl, common map[string]interface{}, fset *token.FileSet, src string, info *types.Info, importPaths map[string]string, minTokens int, qualifierRewrite string) []ChromaDocument {
+	if funcDecl.Body == nil {
+		return nil
+	}
+	symbol := funcSymbol(funcDecl)
+	var children []ChromaDocument
+	for _, c := range findClosures(funcDecl.Body, symbol) {
+		start, end := fset.Position(c.Lit.Pos()), fset.Position(c.Lit.End())
+		if start.Offset < 0 || end.Offset > len(src) || start.Offset > end.Offset {
+			continue
+		}
+		code := src[start.Offset:end.Offset]
+		if EstimateTokens(code) < minTokens {
+			continue
+		}
+		metadata := make(map[string]interface{}, len(common)+8)
+		for k, v := range common {
+			metadata[k] = v
+		}
+		metadata["entity_type"] = "closure"
+		metadata["entity_name"] = c.Name
+		metadata["parent_entity"] = parent.Metadata["entity_name"]
+		metadata["parent_id"] = parent.ID
+		metadata["signature"] = getSignature(c.Lit.Type, info)
+		metadata["start_line"] = start.Line
+		metadata["end_line"] = end.Line
+		children = append(children, ChromaDocument{
+			ID:       parent.ID + c.Name[len(symbol):],
+			Document: rewriteQualifiers(code, c.Lit, info, importPaths, qualifierRewrite),
+			Metadata: metadata,
+		})
+	}
+	return children
+}
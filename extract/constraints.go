@@ -0,0 +1,147 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// TypeParam is one type parameter of a generic function or type, with its constraint as
+// declared.
+type TypeParam struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint"`
+}
+
+// setTypeParams records the type parameters of a generic declaration as "type_params"
+// and marks it "is_generic". It works from the syntax, so syntax-only chunks are marked
+// too; setTypeParamConstraints adds the expanded constraints when type information is
+// available.
+func setTypeParams(metadata map[string]interface{}, typeParams *ast.FieldList, info *types.Info) {
+	if typeParams == nil || len(typeParams.List) == 0 {
+		return
+	}
+	var params []TypeParam
+	for _, field := range typeParams.List {
+		constraint := getTypeString(field.Type, info)
+		for _, name := range field.Names {
+			params = append(params, TypeParam{Name: name.Name, Constraint: constraint})
+		}
+	}
+	metadata["type_params"] = params
+	metadata["is_generic"] = true
+}
+
+// TypeParamConstraint is the expanded constraint of one type parameter. Terms collects
+// the union terms of the constraint's type set (through embedded constraint interfaces
+// such as cmp.Ordered); Methods is its full method set.
+type TypeParamConstraint struct {
+	Name       string           `json:"name"`
+	Constraint string           `json:"constraint"`
+	Any        bool             `json:"any"`
+	Comparable bool             `json:"comparable"`
+	Methods    []string         `json:"methods,omitempty"`
+	Terms      []ConstraintTerm `json:"terms,omitempty"`
+}
+
+// ConstraintTerm is one term of a union constraint; Tilde marks ~T (underlying type T).
+type ConstraintTerm struct {
+	Type  string `json:"type"`
+	Tilde bool   `json:"tilde,omitempty"`
+}
+
+// setTypeParamConstraints records "type_param_constraints" for a generic declaration,
+// plus "constraint_kinds" (any, comparable, methods, union) as a flat list for filtering,
+// e.g. all generic functions constrained to comparable.
+func setTypeParamConstraints(metadata map[string]interface{}, params *types.TypeParamList) {
+	if params == nil || params.Len() == 0 {
+		return
+	}
+	var constraints []TypeParamConstraint
+	kinds := make(map[string]bool)
+	for i := 0; i < params.Len(); i++ {
+		c := expandConstraint(params.At(i))
+		constraints = append(constraints, c)
+		if c.Any {
+			kinds["any"] = true
+		}
+		if c.Comparable {
+			kinds["comparable"] = true
+		}
+		if len(c.Methods) > 0 {
+			kinds["methods"] = true
+		}
+		if len(c.Terms) > 0 {
+			kinds["union"] = true
+		}
+	}
+	metadata["type_param_constraints"] = constraints
+	metadata["constraint_kinds"] = sortedKeys(kinds)
+}
+
+func expandConstraint(tp *types.TypeParam) TypeParamConstraint {
+	c := TypeParamConstraint{Name: tp.Obj().Name(), Constraint: tp.Constraint().String()}
+	iface, isIface := tp.Constraint().Underlying().(*types.Interface)
+	if !isIface {
+		return c
+	}
+	c.Any = iface.Empty()
+	// IsComparable is also true for unions of comparable types; report only the explicit
+	// comparable constraint, which is what queries for "comparable" mean.
+	c.Comparable = isComparableConstraint(tp.Constraint())
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		c.Methods = append(c.Methods, m.Name()+types.TypeString(m.Type(), nil)[len("func"):])
+	}
+	sort.Strings(c.Methods)
+	c.Terms = unionTerms(iface, make(map[*types.Interface]bool))
+	return c
+}
+
+// isComparableConstraint reports whether t is or embeds the predeclared comparable,
+// directly or through other constraint interfaces.
+func isComparableConstraint(t types.Type) bool {
+	if named, isNamed := t.(*types.Named); isNamed && named.Obj().Pkg() == nil && named.Obj().Name() == "comparable" {
+		return true
+	}
+	iface, isIface := t.Underlying().(*types.Interface)
+	if !isIface {
+		return false
+	}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		if isComparableConstraint(iface.EmbeddedType(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unionTerms collects the union terms reachable from iface's embedded elements.
+func unionTerms(iface *types.Interface, visited map[*types.Interface]bool) []ConstraintTerm {
+	if visited[iface] {
+		return nil
+	}
+	visited[iface] = true
+	var terms []ConstraintTerm
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		switch embedded := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < embedded.Len(); j++ {
+				term := embedded.Term(j)
+				if inner, isIface := term.Type().Underlying().(*types.Interface); isIface && !term.Tilde() {
+					terms = append(terms, unionTerms(inner, visited)...)
+					continue
+				}
+				terms = append(terms, ConstraintTerm{Type: term.Type().String(), Tilde: term.Tilde()})
+			}
+		default:
+			if inner, isIface := embedded.Underlying().(*types.Interface); isIface {
+				terms = append(terms, unionTerms(inner, visited)...)
+			} else {
+				// A single non-interface element, e.g. interface{ ~int }.
+				terms = append(terms, ConstraintTerm{Type: embedded.String()})
+			}
+		}
+	}
+	return terms
+}
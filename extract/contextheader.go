@@ -0,0 +1,30 @@
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// fileContextHeader returns the package clause and import declarations of file, as
+// written in src, so a consumer can rebuild a compilable file around any declaration
+// retrieved from it.
+func fileContextHeader(file *ast.File, fset *token.FileSet, src []byte) string {
+	var b strings.Builder
+	b.WriteString("package " + file.Name.Name + "\n")
+	for _, decl := range file.Decls {
+		genDecl, isGenDecl := decl.(*ast.GenDecl)
+		if !isGenDecl || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		start := fset.Position(genDecl.Pos()).Offset
+		end := fset.Position(genDecl.End()).Offset
+		if start < 0 || end > len(src) || start > end {
+			continue
+		}
+		b.WriteString("\n")
+		b.Write(src[start:end])
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,331 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Describer answers questions about single symbols for interactive tooling, without
+// writing any output files. Only the symbol's own package is chunked; CallerScope
+// (default "./...") is type-checked to find callers and implementers.
+type Describer struct {
+	ProjectPath string
+	Options     ExtractOptions
+	CallerScope []string
+}
+
+// SymbolRef points at a related symbol and, where it is chunked, its chunk ID.
+type SymbolRef struct {
+	QualifiedName string `json:"qualified_name"`
+	ChunkID       string `json:"chunk_id,omitempty"`
+}
+
+// SymbolDescription is everything DescribeSymbol knows about one symbol. For an
+// interface, Implementers lists the concrete types satisfying it; for a concrete type,
+// Implements lists the interfaces it satisfies.
+type SymbolDescription struct {
+	QualifiedName string          `json:"qualified_name"`
+	Kind          string          `json:"kind"`
+	Signature     string          `json:"signature"`
+	Doc           string          `json:"doc,omitempty"`
+	Chunk         *ChromaDocument `json:"chunk,omitempty"`
+	Callers       []SymbolRef     `json:"callers,omitempty"`
+	Callees       []string        `json:"callees,omitempty"`
+	Implementers  []SymbolRef     `json:"implementers,omitempty"`
+	Implements    []SymbolRef     `json:"implements,omitempty"`
+}
+
+// Callers are found through the same static call edges as the "calls" metadata, so
+// calls through interfaces and function values are not listed.
+
+// DescribeSymbol looks up name in the packages matched by pkgPattern. name is a
+// package-level identifier ("NewServer", "Config") or a method as "Type.Method".
+func (d *Describer) DescribeSymbol(ctx context.Context, pkgPattern, name string) (*SymbolDescription, error) {
+	scope := d.CallerScope
+	if len(scope) == 0 {
+		scope = []string{"./..."}
+	}
+
+	// Resolve the pattern to package paths cheaply before the full load.
+	targets, err := packages.Load(&packages.Config{
+		Context:    ctx,
+		Mode:       packages.NeedName,
+		Dir:        d.ProjectPath,
+		BuildFlags: packageBuildFlags(d.Options),
+	}, pkgPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", pkgPattern, err)
+	}
+	targetPaths := make(map[string]bool)
+	for _, pkg := range targets {
+		targetPaths[pkg.PkgPath] = true
+	}
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes |
+			packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedTypesSizes,
+		Fset:       fset,
+		Dir:        d.ProjectPath,
+		BuildFlags: packageBuildFlags(d.Options),
+	}
+	pkgs, err := packages.Load(cfg, append([]string{pkgPattern}, scope...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	var obj types.Object
+	var owner *packages.Package
+	for _, pkg := range pkgs {
+		if !targetPaths[pkg.PkgPath] || pkg.Types == nil {
+			continue
+		}
+		if obj = lookupSymbol(pkg.Types, name); obj != nil {
+			owner = pkg
+			break
+		}
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("symbol %s not found in %s", name, pkgPattern)
+	}
+
+	desc := &SymbolDescription{
+		QualifiedName: symbolQualifiedName(obj),
+		Kind:          symbolKind(obj),
+		Signature:     types.ObjectString(obj, nil),
+	}
+	decl := findDeclaration(owner, obj)
+	if doc := declarationDoc(decl); doc != nil {
+		desc.Doc = doc.Text()
+	}
+
+	if fn, isFunc := obj.(*types.Func); isFunc {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			desc.Callees = calleeNames(collectCallEdges(funcDecl, owner.TypesInfo))
+		}
+		desc.Callers = findCallers(pkgs, fset, fn.FullName(), d.Options.IDScheme)
+	}
+	if typeName, isTypeName := obj.(*types.TypeName); isTypeName {
+		desc.Implementers, desc.Implements = findImplementations(pkgs, fset, typeName, d.Options.IDScheme)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts := d.Options
+	opts.Packages = []string{pkgPattern}
+	result, err := ExtractProject(ctx, d.ProjectPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Chunks {
+		if qualified, _ := result.Chunks[i].Metadata["qualified_name"].(string); qualified == desc.QualifiedName {
+			desc.Chunk = &result.Chunks[i]
+			break
+		}
+	}
+	return desc, nil
+}
+
+// lookupSymbol resolves "Name" in the package scope or "Type.Method" on a named type.
+func lookupSymbol(pkg *types.Package, name string) types.Object {
+	typeName, method, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		return pkg.Scope().Lookup(name)
+	}
+	tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg, method)
+	if fn, isFunc := obj.(*types.Func); isFunc {
+		return fn
+	}
+	return nil
+}
+
+// symbolQualifiedName matches the qualified_name metadata of the symbol's chunk.
+func symbolQualifiedName(obj types.Object) string {
+	if fn, isFunc := obj.(*types.Func); isFunc {
+		return fn.FullName()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+func symbolKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "function"
+	case *types.TypeName:
+		if types.IsInterface(o.Type()) {
+			return "interface"
+		}
+		return "type"
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		return "var"
+	}
+	return "object"
+}
+
+// findDeclaration returns the FuncDecl, TypeSpec or ValueSpec declaring obj, along with
+// the enclosing GenDecl for specs so that ungrouped specs can fall back to its doc.
+func findDeclaration(pkg *packages.Package, obj types.Object) ast.Node {
+	var found ast.Node
+	for _, file := range pkg.Syntax {
+		if found != nil {
+			break
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Name.Pos() == obj.Pos() {
+					found = d
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if s.Name.Pos() == obj.Pos() {
+							found = &declaredSpec{genDecl: d, spec: s}
+						}
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							if n.Pos() == obj.Pos() {
+								found = &declaredSpec{genDecl: d, spec: s}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return found
+}
+
+// declaredSpec pairs a spec with its GenDecl.
+type declaredSpec struct {
+	genDecl *ast.GenDecl
+	spec    ast.Spec
+}
+
+func (s *declaredSpec) Pos() token.Pos { return s.spec.Pos() }
+func (s *declaredSpec) End() token.Pos { return s.spec.End() }
+
+func declarationDoc(decl ast.Node) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *declaredSpec:
+		return declDocComment(d.genDecl, d.spec)
+	}
+	return nil
+}
+
+// findCallers returns every function or method in pkgs with a static call to callee.
+func findCallers(pkgs []*packages.Package, fset *token.FileSet, callee, idScheme string) []SymbolRef {
+	var callers []SymbolRef
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				funcDecl, isFuncDecl := decl.(*ast.FuncDecl)
+				if !isFuncDecl {
+					continue
+				}
+				for _, edge := range collectCallEdges(funcDecl, pkg.TypesInfo) {
+					if edge.Callee != callee {
+						continue
+					}
+					ref := SymbolRef{QualifiedName: funcDecl.Name.Name}
+					if fn, isFunc := pkg.TypesInfo.Defs[funcDecl.Name].(*types.Func); isFunc {
+						ref.QualifiedName = fn.FullName()
+					}
+					start, end := fset.Position(funcDecl.Pos()), fset.Position(funcDecl.End())
+					if idScheme == IDSchemePositional {
+						ref.ChunkID = positionalChunkID(start.Filename, start.Line, end.Line, funcDecl.Name.Name)
+					} else {
+						ref.ChunkID = symbolChunkID(packageImportPath(pkg), funcSymbol(funcDecl), file, start.Filename, funcOccurrence(file, funcDecl))
+					}
+					callers = append(callers, ref)
+					break
+				}
+			}
+		}
+	}
+	sort.Slice(callers, func(i, j int) bool { return callers[i].QualifiedName < callers[j].QualifiedName })
+	return callers
+}
+
+// findImplementations relates target to the named types declared in pkgs: concrete types
+// implementing it if it is an interface, or interfaces it implements otherwise.
+func findImplementations(pkgs []*packages.Package, fset *token.FileSet, target *types.TypeName, idScheme string) (implementers, implements []SymbolRef) {
+	typeChunkIDs := buildTypeChunkIndex(pkgs, fset, idScheme)
+	targetIface, targetIsIface := target.Type().Underlying().(*types.Interface)
+	if isGenericType(target) {
+		return nil, nil
+	}
+
+	seen := make(map[*types.TypeName]bool)
+	for _, pkg := range pkgs {
+		if pkg.Types == nil || pkg.TypesInfo == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn == target || seen[tn] || tn.IsAlias() || isGenericType(tn) {
+				continue
+			}
+			seen[tn] = true
+			ref := SymbolRef{QualifiedName: tn.Pkg().Path() + "." + tn.Name(), ChunkID: typeChunkIDs[tn]}
+			iface, isIface := tn.Type().Underlying().(*types.Interface)
+
+			switch {
+			case targetIsIface && !isIface:
+				if targetIface.NumMethods() > 0 && implementsEither(tn.Type(), targetIface) {
+					implementers = append(implementers, ref)
+				}
+			case !targetIsIface && isIface:
+				if iface.NumMethods() > 0 && implementsEither(target.Type(), iface) {
+					implements = append(implements, ref)
+				}
+			}
+		}
+	}
+	sort.Slice(implementers, func(i, j int) bool { return implementers[i].QualifiedName < implementers[j].QualifiedName })
+	sort.Slice(implements, func(i, j int) bool { return implements[i].QualifiedName < implements[j].QualifiedName })
+	return implementers, implements
+}
+
+// isGenericType reports whether tn declares type parameters; such types only implement
+// interfaces once instantiated.
+func isGenericType(tn *types.TypeName) bool {
+	named, isNamed := tn.Type().(*types.Named)
+	return isNamed && named.TypeParams().Len() > 0
+}
+
+// implementsEither reports whether t or *t implements iface.
+func implementsEither(t types.Type, iface *types.Interface) bool {
+	if types.Implements(t, iface) {
+		return true
+	}
+	if _, isPtr := t.(*types.Pointer); isPtr {
+		return false
+	}
+	return types.Implements(types.NewPointer(t), iface)
+}
@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// chunkDiagnostic is a load, parse or type-check error located at a source line.
+type chunkDiagnostic struct {
+	Line    int
+	Message string
+}
+
+// packageDiagnostics indexes a package's errors by absolute file path. Errors without a
+// usable position (e.g. "-" or module-level failures) cannot be attributed to a chunk and
+// are omitted; they are still logged during loading.
+func packageDiagnostics(pkg *packages.Package) map[string][]chunkDiagnostic {
+	byFile := make(map[string][]chunkDiagnostic)
+	seen := make(map[string]bool)
+
+	add := func(file string, line int, message string) {
+		if file == "" || line <= 0 {
+			return
+		}
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		key := fmt.Sprintf("%s:%d:%s", file, line, message)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		byFile[file] = append(byFile[file], chunkDiagnostic{Line: line, Message: message})
+	}
+
+	for _, typeErr := range pkg.TypeErrors {
+		if typeErr.Fset == nil {
+			continue
+		}
+		position := typeErr.Fset.Position(typeErr.Pos)
+		add(position.Filename, position.Line, typeErr.Msg)
+	}
+	for _, pkgErr := range pkg.Errors {
+		file, line := parseErrorPosition(pkgErr.Pos)
+		add(file, line, pkgErr.Msg)
+	}
+	return byFile
+}
+
+// parseErrorPosition splits a packages.Error position ("file:line:col" or "file:line").
+func parseErrorPosition(pos string) (string, int) {
+	parts := strings.Split(pos, ":")
+	// Try "file:line:col" first, then "file:line"; file paths may themselves contain colons.
+	for _, trailing := range []int{2, 1} {
+		if len(parts) <= trailing {
+			continue
+		}
+		line, err := strconv.Atoi(parts[len(parts)-trailing])
+		if err != nil {
+			continue
+		}
+		if trailing == 2 {
+			if _, err := strconv.Atoi(parts[len(parts)-1]); err != nil {
+				continue
+			}
+		}
+		return strings.Join(parts[:len(parts)-trailing], ":"), line
+	}
+	return "", 0
+}
+
+// attachDiagnostics marks each chunk with has_errors and lists the diagnostics whose
+// line falls inside the chunk's line range, so consumers know a snippet may not compile.
+func attachDiagnostics(chunks []ChromaDocument, diagnostics []chunkDiagnostic) {
+	for i := range chunks {
+		metadata := chunks[i].Metadata
+		startLine, _ := metadata["start_line"].(int)
+		endLine, _ := metadata["end_line"].(int)
+
+		var messages []string
+		for _, diag := range diagnostics {
+			if diag.Line >= startLine && diag.Line <= endLine {
+				messages = append(messages, fmt.Sprintf("line %d: %s", diag.Line, diag.Message))
+			}
+		}
+		metadata["has_errors"] = len(messages) > 0
+		if len(messages) > 0 {
+			metadata["errors"] = messages
+		}
+	}
+}
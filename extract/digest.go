@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// maxDigestValueLen bounds how much of a variable's initializer is shown in the digest,
+// keeping the chunk compact when values are large composite literals.
+const maxDigestValueLen = 80
+
+// buildValueDigest produces a synthetic chunk listing every exported package-level
+// constant and variable with its type and value, answering "what knobs does this package
+// expose" in one retrieval hit. It returns false if the package exports none.
+func buildValueDigest(pkg *packages.Package, files []*ast.File, info *types.Info, typed bool) (ChromaDocument, bool) {
+	var constLines, varLines, names []string
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, isGenDecl := decl.(*ast.GenDecl)
+			if !isGenDecl || (genDecl.Tok != token.CONST && genDecl.Tok != token.VAR) {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				valueSpec, isValueSpec := spec.(*ast.ValueSpec)
+				if !isValueSpec {
+					continue
+				}
+				for i, name := range valueSpec.Names {
+					if !name.IsExported() {
+						continue
+					}
+					line := digestLine(genDecl.Tok, valueSpec, i, pkg.Types, info)
+					if genDecl.Tok == token.CONST {
+						constLines = append(constLines, line)
+					} else {
+						varLines = append(varLines, line)
+					}
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return ChromaDocument{}, false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Exported constants and variables of package %s (%s)\n", pkg.Name, pkg.PkgPath)
+	if len(constLines) > 0 {
+		b.WriteString("const (\n")
+		for _, line := range constLines {
+			b.WriteString("\t" + line + "\n")
+		}
+		b.WriteString(")\n")
+	}
+	if len(varLines) > 0 {
+		b.WriteString("var (\n")
+		for _, line := range varLines {
+			b.WriteString("\t" + line + "\n")
+		}
+		b.WriteString(")\n")
+	}
+
+	packageDir := ""
+	if len(pkg.GoFiles) > 0 {
+		packageDir = filepath.Dir(pkg.GoFiles[0])
+	}
+
+	return ChromaDocument{
+		ID:       fmt.Sprintf("%s:api_digest", pkg.PkgPath),
+		Document: b.String(),
+		Metadata: map[string]interface{}{
+			"file_path":          packageDir,
+			"package_name":       pkg.Name,
+			"package_path":       pkg.PkgPath,
+			"entity_type":        "api_digest",
+			"entity_name":        pkg.Name + ".api_digest",
+			"typed":              typed,
+			"exported_constants": len(constLines),
+			"exported_variables": len(varLines),
+			"symbols":            names,
+		},
+	}, true
+}
+
+// digestLine renders "Name Type = Value" for the i-th name of a value spec. Constant
+// values come from the type checker (so iota is evaluated); variables show their
+// initializer expression, truncated to maxDigestValueLen bytes on a rune boundary.
+func digestLine(tok token.Token, valueSpec *ast.ValueSpec, i int, pkgTypes *types.Package, info *types.Info) string {
+	name := valueSpec.Names[i]
+	typeStr := ""
+	valueStr := ""
+
+	if info != nil {
+		if obj := info.Defs[name]; obj != nil {
+			typeStr = types.TypeString(obj.Type(), types.RelativeTo(pkgTypes))
+			if constObj, isConst := obj.(*types.Const); isConst && tok == token.CONST {
+				valueStr = constObj.Val().ExactString()
+			}
+		}
+	}
+	if typeStr == "" && valueSpec.Type != nil {
+		typeStr = types.ExprString(valueSpec.Type)
+	}
+	if valueStr == "" && i < len(valueSpec.Values) {
+		valueStr = types.ExprString(valueSpec.Values[i])
+		if len(valueStr) > maxDigestValueLen {
+			cut := maxDigestValueLen
+			for cut > 0 && !utf8.RuneStart(valueStr[cut]) {
+				cut--
+			}
+			valueStr = valueStr[:cut] + "..."
+		}
+	}
+
+	line := name.Name
+	if typeStr != "" {
+		line += " " + typeStr
+	}
+	if valueStr != "" {
+		line += " = " + valueStr
+	}
+	return line
+}
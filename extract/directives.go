@@ -0,0 +1,145 @@
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// isDirective reports whether a comment is a compiler or tool directive: //go:build,
+// //go:generate, //go:noinline and other //tool:name comments, // +build, //line,
+// //export, //extern and //nolint.
+func isDirective(text string) bool {
+	for _, prefix := range []string{"//line ", "//extern ", "//export ", "// +build "} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	if text == "//nolint" || strings.HasPrefix(text, "//nolint:") || strings.HasPrefix(text, "//nolint ") {
+		return true
+	}
+	// //[a-z0-9]+:[a-z0-9], as recognized by the gc toolchain.
+	colon := strings.Index(text, ":")
+	if !strings.HasPrefix(text, "//") || colon <= 2 || colon+1 >= len(text) {
+		return false
+	}
+	isWordByte := func(c byte) bool { return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' }
+	for i := 2; i < colon; i++ {
+		if !isWordByte(text[i]) {
+			return false
+		}
+	}
+	return isWordByte(text[colon+1])
+}
+
+// directivesBetween returns the directive comments of file between from and to.
+func directivesBetween(file *ast.File, from, to token.Pos) []*ast.Comment {
+	var directives []*ast.Comment
+	for _, group := range file.Comments {
+		if group.End() <= from || group.Pos() >= to {
+			continue
+		}
+		for _, c := range group.List {
+			if c.Pos() > from && c.End() < to && isDirective(c.Text) {
+				directives = append(directives, c)
+			}
+		}
+	}
+	return directives
+}
+
+// fileDirectives returns the directives above the package clause, such as build
+// constraints, which apply to every declaration of the file.
+func fileDirectives(file *ast.File) []*ast.Comment {
+	return directivesBetween(file, token.NoPos, file.Package)
+}
+
+// trailingDirectives returns the directives after end on its line, such as a //nolint
+// behind a declaration.
+func trailingDirectives(file *ast.File, fset *token.FileSet, end token.Pos) []*ast.Comment {
+	line := fset.Position(end).Line
+	var directives []*ast.Comment
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if c.Pos() >= end && fset.Position(c.Pos()).Line == line && isDirective(c.Text) {
+				directives = append(directives, c)
+			}
+		}
+	}
+	return directives
+}
+
+// specDirectives returns the directives in the doc comment of a spec inside a
+// parenthesized declaration group and in the line comment of any spec.
+func specDirectives(genDecl *ast.GenDecl, spec ast.Spec) (leading, trailing []*ast.Comment) {
+	var doc, line *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		doc, line = s.Doc, s.Comment
+	case *ast.ValueSpec:
+		doc, line = s.Doc, s.Comment
+	}
+	if genDecl.Lparen.IsValid() {
+		leading = groupDirectives(doc)
+	}
+	return leading, groupDirectives(line)
+}
+
+// groupDirectives returns the directives of a comment group.
+func groupDirectives(group *ast.CommentGroup) []*ast.Comment {
+	if group == nil {
+		return nil
+	}
+	var directives []*ast.Comment
+	for _, c := range group.List {
+		if isDirective(c.Text) {
+			directives = append(directives, c)
+		}
+	}
+	return directives
+}
+
+// mergeDirectives concatenates lists of directives, dropping repeated ones.
+func mergeDirectives(lists ...[]*ast.Comment) []*ast.Comment {
+	var merged []*ast.Comment
+	seen := make(map[*ast.Comment]bool)
+	for _, list := range lists {
+		for _, c := range list {
+			if !seen[c] {
+				seen[c] = true
+				merged = append(merged, c)
+			}
+		}
+	}
+	return merged
+}
+
+// setDirectives records the text of directives as "directives".
+func setDirectives(metadata map[string]interface{}, directives []*ast.Comment) {
+	if len(directives) == 0 {
+		return
+	}
+	texts := make([]string, len(directives))
+	for i, c := range directives {
+		texts[i] = c.Text
+	}
+	metadata["directives"] = texts
+}
+
+// withDirectives prepends the leading directives that are not already part of the chunk
+// text, that is, all but those inside doc when the doc comment was included, and appends
+// the trailing ones to its last line.
+func withDirectives(chunkCode string, leading, trailing []*ast.Comment, doc *ast.CommentGroup, docIncluded bool) string {
+	var b strings.Builder
+	for _, c := range leading {
+		if docIncluded && doc != nil && c.Pos() >= doc.Pos() && c.End() <= doc.End() {
+			continue
+		}
+		b.WriteString(c.Text + "\n")
+	}
+	b.WriteString(chunkCode)
+	for _, c := range trailing {
+		b.WriteString(" " + c.Text)
+	}
+	return b.String()
+}
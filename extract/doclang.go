@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"go/ast"
+	"go/doc"
+	"strings"
+	"unicode"
+)
+
+// minDocLanguageLetters is the number of letters a doc comment needs before its language
+// is guessed; shorter comments are mostly identifiers and carry no signal.
+const minDocLanguageLetters = 20
+
+// scriptLanguages maps writing systems that (nearly) identify a language on their own to
+// ISO 639-1 codes. Han without kana is reported as Chinese.
+var scriptLanguages = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// latinStopwords are frequent function words used to tell Latin-script languages apart.
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "in", "it", "for", "this", "that", "returns", "if", "with", "be", "an"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "für", "wird", "den", "von", "gibt"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "du", "pour", "que", "dans", "pas", "retourne", "si"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "de", "para", "que", "en", "con", "devuelve", "si"},
+	"pt": {"o", "a", "os", "as", "e", "é", "um", "uma", "do", "da", "para", "que", "com", "não", "retorna"},
+	"it": {"il", "lo", "la", "gli", "e", "è", "un", "una", "di", "per", "che", "con", "non", "restituisce", "se"},
+	"nl": {"de", "het", "een", "en", "is", "van", "voor", "dat", "niet", "met", "geeft", "wordt", "als", "op", "te"},
+}
+
+// declDocComment returns the doc comment of a declaration chunk. A spec inside a
+// parenthesized group only uses its own comment; an ungrouped spec inherits the
+// comment written above the keyword.
+func declDocComment(genDecl *ast.GenDecl, spec ast.Spec) *ast.CommentGroup {
+	var doc *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		doc = s.Doc
+	case *ast.ValueSpec:
+		doc = s.Doc
+	}
+	if doc == nil && !genDecl.Lparen.IsValid() {
+		doc = genDecl.Doc
+	}
+	return doc
+}
+
+// setDocMetadata records a doc comment's text, without comment markers and directives,
+// as "doc" and its first sentence, as godoc shows it in listings, as "doc_summary".
+func setDocMetadata(metadata map[string]interface{}, comment *ast.CommentGroup) {
+	if comment == nil {
+		return
+	}
+	text := strings.TrimSpace(comment.Text())
+	if text == "" {
+		return
+	}
+	metadata["doc"] = text
+	if summary := new(doc.Package).Synopsis(text); summary != "" {
+		metadata["doc_summary"] = summary
+	}
+}
+
+// setDocLanguage records the detected natural language of a doc comment as "language".
+func setDocLanguage(metadata map[string]interface{}, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	if language := detectTextLanguage(doc.Text()); language != "" {
+		metadata["language"] = language
+	}
+}
+
+// detectTextLanguage guesses the ISO 639-1 language of prose. Non-Latin scripts are
+// identified by character ranges; Latin-script text is scored against stopword lists.
+// It returns "" when the text is too short or gives no clear signal.
+func detectTextLanguage(text string) string {
+	letters := 0
+	scriptCounts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				scriptCounts[script.language]++
+				break
+			}
+		}
+	}
+	if letters < minDocLanguageLetters {
+		return ""
+	}
+
+	// Japanese mixes kana with Han, so any kana wins over Chinese.
+	if scriptCounts["ja"] > 0 {
+		scriptCounts["ja"] += scriptCounts["zh"]
+		delete(scriptCounts, "zh")
+	}
+	best, bestCount := "", 0
+	for language, count := range scriptCounts {
+		if count > bestCount || (count == bestCount && language < best) {
+			best, bestCount = language, count
+		}
+	}
+	// Code identifiers keep even non-English comments largely Latin; a fifth of the
+	// letters in another script is a strong enough signal.
+	if bestCount*5 >= letters {
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	scores := make(map[string]int)
+	for _, word := range words {
+		for language, stopwords := range latinStopwords {
+			for _, stopword := range stopwords {
+				if word == stopword {
+					scores[language]++
+					break
+				}
+			}
+		}
+	}
+	best, bestScore, tied := "", 0, false
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = language, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if bestScore < 2 || tied {
+		return ""
+	}
+	return best
+}
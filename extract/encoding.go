@@ -0,0 +1,53 @@
+package extract
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policies for source files that are not valid UTF-8.
+const (
+	// InvalidUTF8Transcode decodes each invalid byte as Latin-1 (ISO-8859-1), which
+	// recovers the text of legacy-encoded comments and string literals while leaving
+	// valid UTF-8 sequences untouched.
+	InvalidUTF8Transcode = "transcode"
+	// InvalidUTF8Replace substitutes U+FFFD for each invalid sequence.
+	InvalidUTF8Replace = "replace"
+	// InvalidUTF8Skip drops the whole file with a warning.
+	InvalidUTF8Skip = "skip"
+)
+
+// validateInvalidUTF8Policy checks the value of --invalid-utf8.
+func validateInvalidUTF8Policy(policy string) error {
+	switch policy {
+	case InvalidUTF8Transcode, InvalidUTF8Replace, InvalidUTF8Skip:
+		return nil
+	}
+	return fmt.Errorf("unknown invalid UTF-8 policy %q (want %s, %s or %s)",
+		policy, InvalidUTF8Transcode, InvalidUTF8Replace, InvalidUTF8Skip)
+}
+
+// repairUTF8 returns text as valid UTF-8 according to the policy. The same source must be
+// repaired identically before parsing and before slicing, so that AST offsets stay valid.
+func repairUTF8(text, policy string) string {
+	if utf8.ValidString(text) {
+		return text
+	}
+	if policy == InvalidUTF8Replace {
+		return strings.ToValidUTF8(text, "�")
+	}
+
+	var b strings.Builder
+	b.Grow(len(text) + len(text)/8)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(rune(text[i])) // Latin-1 code points map 1:1 onto the first 256 runes.
+		} else {
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package extract
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// isEntrypointPackage reports whether pkg is excluded from the library corpus by the
+// SkipMain/SkipCmd options: a "package main" program, or a package below a cmd/ directory
+// of projectPath.
+func isEntrypointPackage(pkg *packages.Package, projectPath string, opts ExtractOptions) bool {
+	if opts.SkipMain && pkg.Name == "main" {
+		return true
+	}
+	if opts.SkipCmd && len(pkg.GoFiles) > 0 {
+		rel, err := filepath.Rel(projectPath, filepath.Dir(pkg.GoFiles[0]))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return false
+		}
+		for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+			if elem == "cmd" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package extract
+
+import (
+	"go/ast"
+	"go/doc"
+)
+
+// fileExamples returns the testable examples of a _test.go file, as recognized by go
+// test, by function name.
+func fileExamples(file *ast.File) map[string]*doc.Example {
+	examples := make(map[string]*doc.Example)
+	for _, example := range doc.Examples(file) {
+		examples["Example"+example.Name] = example
+	}
+	return examples
+}
+
+// setExampleMetadata marks an example function chunk with entity_type "example", the
+// symbol it documents as "example_of" (the import path of the package under test and
+// "F", "T" or "T.M", or just the import path for a package example) and any name suffix
+// as "example_suffix", its expected output as "expected_output" and "output_unordered"
+// for "// Unordered output:".
+func setExampleMetadata(metadata map[string]interface{}, example *doc.Example, importPath string) {
+	metadata["entity_type"] = "example"
+	symbol, suffix := testedSymbol(example.Name)
+	if suffix != "" {
+		metadata["example_suffix"] = suffix
+	}
+	metadata["example_of"] = testedSymbolPath(importPath, symbol)
+	if example.Output != "" || example.EmptyOutput {
+		metadata["expected_output"] = example.Output
+	}
+	if example.Unordered {
+		metadata["output_unordered"] = true
+	}
+}
@@ -0,0 +1,88 @@
+package extract
+
+import (
+	"encoding/json"
+	"go/ast"
+	"log"
+	"reflect"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// MetadataExtractor adds metadata to a declaration chunk, e.g. the owning service or
+// team. node is the *ast.FuncDecl, *ast.TypeSpec or *ast.ValueSpec of the chunk. New
+// fields go in meta.Extra (allocate it if nil); core fields may be changed too.
+type MetadataExtractor func(pkg *packages.Package, node ast.Node, meta *ChunkMetadata)
+
+var (
+	extractorsMu       sync.Mutex
+	metadataExtractors []MetadataExtractor
+)
+
+// RegisterExtractor adds an extractor that is run, in registration order, on every
+// declaration chunk before its ID is computed, so an IDFunc sees the added metadata.
+// API digest chunks are not passed to extractors.
+func RegisterExtractor(fn MetadataExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	metadataExtractors = append(metadataExtractors, fn)
+}
+
+// registeredExtractors returns a snapshot of the registered extractors.
+func registeredExtractors() []MetadataExtractor {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	return append([]MetadataExtractor(nil), metadataExtractors...)
+}
+
+// runExtractors applies extractors to a chunk's metadata. Only fields the extractors
+// changed are written back, so the others keep their Go types.
+func runExtractors(extractors []MetadataExtractor, pkg *packages.Package, node ast.Node, chunkName string, metadata map[string]interface{}) {
+	if len(extractors) == 0 {
+		return
+	}
+	meta, err := ChromaDocument{ID: chunkName, Metadata: metadata}.TypedMetadata()
+	if err != nil {
+		log.Printf("Warning: skipping metadata extractors: %v", err)
+		return
+	}
+	for _, extract := range extractors {
+		extract(pkg, node, &meta)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		log.Printf("Warning: discarding metadata from extractors for %s: %v", chunkName, err)
+		return
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(data, &updated); err != nil {
+		log.Printf("Warning: discarding metadata from extractors for %s: %v", chunkName, err)
+		return
+	}
+	for key := range metadata {
+		if _, ok := updated[key]; !ok {
+			delete(metadata, key)
+		}
+	}
+	for key, value := range updated {
+		if old, ok := metadata[key]; ok && sameJSON(old, value) {
+			continue
+		}
+		metadata[key] = value
+	}
+}
+
+// sameJSON reports whether a and b encode to the same JSON.
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal(ja, &va) != nil || json.Unmarshal(jb, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
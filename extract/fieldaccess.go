@@ -0,0 +1,123 @@
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// receiverFieldAccess reports which fields of the method's receiver are read and which
+// are written in its body (including closures). A field counts as written when it is
+// assigned, incremented, has its address taken, or has an element assigned through it
+// (e.g. r.cache[k] = v). It returns nil slices when type information is unavailable.
+func receiverFieldAccess(funcDecl *ast.FuncDecl, info *types.Info) (read []string, written []string) {
+	if info == nil || funcDecl.Body == nil || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return nil, nil
+	}
+	recvField := funcDecl.Recv.List[0]
+	if len(recvField.Names) == 0 {
+		return nil, nil // Unnamed receiver: the body cannot refer to it.
+	}
+	recvObj := info.Defs[recvField.Names[0]]
+	if recvObj == nil {
+		return nil, nil
+	}
+
+	// fieldName returns the receiver field selected by sel, if sel is "recv.field".
+	fieldName := func(sel *ast.SelectorExpr) (string, bool) {
+		ident, isIdent := sel.X.(*ast.Ident)
+		if !isIdent || info.Uses[ident] != recvObj {
+			return "", false
+		}
+		if selection, ok := info.Selections[sel]; !ok || selection.Kind() != types.FieldVal {
+			return "", false
+		}
+		return sel.Sel.Name, true
+	}
+
+	// rootField walks down an lvalue expression (r.a.b[i], *r.p, (r.x)) to the receiver
+	// field it ultimately mutates.
+	rootField := func(expr ast.Expr) (string, bool) {
+		for {
+			switch e := expr.(type) {
+			case *ast.SelectorExpr:
+				if name, ok := fieldName(e); ok {
+					return name, true
+				}
+				expr = e.X
+			case *ast.IndexExpr:
+				expr = e.X
+			case *ast.ParenExpr:
+				expr = e.X
+			case *ast.StarExpr:
+				expr = e.X
+			default:
+				return "", false
+			}
+		}
+	}
+
+	readSet := make(map[string]bool)
+	writeSet := make(map[string]bool)
+	// pureTargets are selector expressions that are only assigned (plain "="), never read.
+	pureTargets := make(map[*ast.SelectorExpr]bool)
+
+	markWrite := func(expr ast.Expr) {
+		if name, ok := rootField(expr); ok {
+			writeSet[name] = true
+		}
+	}
+
+	ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				markWrite(lhs)
+				if sel, isSel := lhs.(*ast.SelectorExpr); isSel && n.Tok == token.ASSIGN {
+					pureTargets[sel] = true
+				}
+			}
+		case *ast.IncDecStmt:
+			markWrite(n.X)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				markWrite(n.X)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				for _, target := range []ast.Expr{n.Key, n.Value} {
+					if target == nil {
+						continue
+					}
+					markWrite(target)
+					if sel, isSel := target.(*ast.SelectorExpr); isSel {
+						pureTargets[sel] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
+		if sel, isSel := node.(*ast.SelectorExpr); isSel && !pureTargets[sel] {
+			if name, ok := fieldName(sel); ok {
+				readSet[name] = true
+			}
+		}
+		return true
+	})
+
+	return sortedKeys(readSet), sortedKeys(writeSet)
+}
+
+// sortedKeys returns the keys of a string set in ascending order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
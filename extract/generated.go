@@ -0,0 +1,35 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+const (
+	// GeneratedInclude indexes generated files and marks their chunks generated=true.
+	GeneratedInclude = "include"
+	// GeneratedSkip leaves generated files out.
+	GeneratedSkip = "skip"
+)
+
+// validateGeneratedPolicy checks the value of -generated.
+func validateGeneratedPolicy(policy string) error {
+	switch policy {
+	case "", GeneratedInclude, GeneratedSkip:
+		return nil
+	}
+	return fmt.Errorf("unknown generated-file policy %q (want %s or %s)", policy, GeneratedInclude, GeneratedSkip)
+}
+
+// splitGeneratedFiles separates the files with the standard "// Code generated ... DO NOT
+// EDIT." header from the others.
+func splitGeneratedFiles(files []*ast.File) (kept, generated []*ast.File) {
+	for _, file := range files {
+		if ast.IsGenerated(file) {
+			generated = append(generated, file)
+		} else {
+			kept = append(kept, file)
+		}
+	}
+	return kept, generated
+}
@@ -0,0 +1,144 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEmbeddedAssetBytes bounds the size of an embedded file emitted as a chunk.
+const maxEmbeddedAssetBytes = 64 << 10
+
+// embedPatterns returns the patterns of the //go:embed directives among directives, with
+// quoted patterns unquoted.
+func embedPatterns(directives []*ast.Comment) []string {
+	var patterns []string
+	for _, c := range directives {
+		if !strings.HasPrefix(c.Text, "//go:embed ") {
+			continue
+		}
+		args := strings.TrimSpace(strings.TrimPrefix(c.Text, "//go:embed "))
+		for args != "" {
+			var pattern string
+			if args[0] == '"' || args[0] == '`' {
+				quoted, err := strconv.QuotedPrefix(args)
+				if err != nil {
+					break
+				}
+				pattern, _ = strconv.Unquote(quoted)
+				args = args[len(quoted):]
+			} else {
+				end := strings.IndexAny(args, " \t")
+				if end < 0 {
+					end = len(args)
+				}
+				pattern, args = args[:end], args[end:]
+			}
+			patterns = append(patterns, pattern)
+			args = strings.TrimLeft(args, " \t")
+		}
+	}
+	return patterns
+}
+
+// embeddedFiles resolves the embed patterns of one variable against the package
+// directory: a directory embeds the files below it, leaving out those whose names start
+// with "." or "_" unless the pattern has the "all:" prefix. Only files in embedFiles, the
+// package's embedded files as validated by the go command (packages.NeedEmbedFiles), are
+// kept, so patterns reaching outside the module or matching symlinks and other irregular
+// files embed nothing. It returns the files' paths relative to dir, sorted.
+func embeddedFiles(dir string, patterns []string, embedFiles []string) []string {
+	valid := make(map[string]bool, len(embedFiles))
+	for _, path := range embedFiles {
+		valid[filepath.Clean(path)] = true
+	}
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		all := strings.HasPrefix(pattern, "all:")
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				seen[filepath.Clean(match)] = true
+				continue
+			}
+			filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				name := info.Name()
+				if path != match && !all && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !info.IsDir() {
+					seen[filepath.Clean(path)] = true
+				}
+				return nil
+			})
+		}
+	}
+	files := make([]string, 0, len(seen))
+	for path := range seen {
+		if !valid[path] {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// embeddedAssetChunks returns a chunk per text file embedded into the variable of parent,
+// such as a template, SQL query or config, with entity_type "embedded_asset", the file's
+// path relative to the package as entity_name, the variable as "embedded_by" and the
+// variable's chunk ID as "parent_id". Binary files and files over
+// maxEmbeddedAssetBytes are left out.
+func embeddedAssetChunks(parent ChromaDocument, dir string, patterns, embedFiles []string, common map[string]interface{}) []ChromaDocument {
+	var chunks []ChromaDocument
+	for _, rel := range embeddedFiles(dir, patterns, embedFiles) {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Printf("Warning: failed to read embedded file %s: %v", path, err)
+			continue
+		}
+		if len(content) > maxEmbeddedAssetBytes || !utf8.Valid(content) || strings.ContainsRune(string(content), 0) {
+			continue
+		}
+		metadata := make(map[string]interface{}, len(common)+6)
+		for k, v := range common {
+			metadata[k] = v
+		}
+		metadata["file_path"] = path
+		metadata["entity_type"] = "embedded_asset"
+		metadata["entity_name"] = rel
+		metadata["embedded_by"] = parent.Metadata["entity_name"]
+		metadata["parent_id"] = parent.ID
+		metadata["start_line"] = 1
+		metadata["end_line"] = strings.Count(strings.TrimSuffix(string(content), "\n"), "\n") + 1
+		chunks = append(chunks, ChromaDocument{
+			ID:       fmt.Sprintf("%s:embed:%s", parent.ID, rel),
+			Document: string(content),
+			Metadata: metadata,
+		})
+	}
+	return chunks
+}
@@ -0,0 +1,43 @@
+package extract
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	// GranularityDecl emits one chunk per declaration.
+	GranularityDecl = "decl"
+	// GranularityFile emits one chunk per source file instead.
+	GranularityFile = "file"
+	// GranularityBoth emits the per-declaration chunks and a chunk per file.
+	GranularityBoth = "both"
+	// GranularityType emits one chunk per declaration, except that the methods of a type
+	// are merged into the type's chunk (see mergeMethodsIntoTypes).
+	GranularityType = "type"
+)
+
+// validateGranularity checks the value of -granularity.
+func validateGranularity(granularity string) error {
+	switch granularity {
+	case "", GranularityDecl, GranularityFile, GranularityBoth, GranularityType:
+		return nil
+	}
+	return fmt.Errorf("unknown granularity %q (want %s, %s, %s or %s)", granularity, GranularityDecl, GranularityFile, GranularityBoth, GranularityType)
+}
+
+// fileChunkID returns the symbol-scheme ID of a whole-file chunk: the package's import
+// path and the file name, e.g. "example.com/app/server:file:server.go".
+func fileChunkID(importPath, filePath string) string {
+	return importPath + ":file:" + filepath.Base(filePath)
+}
+
+// setFileChunkMetadata describes a whole-file chunk of lineCount lines with numDecls
+// top-level declarations.
+func setFileChunkMetadata(metadata map[string]interface{}, filePath string, lineCount, numDecls int) {
+	metadata["entity_type"] = "file"
+	metadata["entity_name"] = filepath.Base(filePath)
+	metadata["start_line"] = 1
+	metadata["end_line"] = lineCount
+	metadata["declaration_count"] = numDecls
+}
@@ -0,0 +1,73 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// groupSmallSpecs combines runs of at least two consecutive var or const specs of a
+// parenthesized declaration, each under maxTokens estimated tokens, into one chunk, so
+// tables of one-line constants do not become thousands of near-empty documents. chunks
+// are the chunks of specs, in order; text returns the chunk text of the specs from first
+// to last. A run stops before it would exceed DefaultTokenLimit. The combined chunk has
+// the ID of its first spec's chunk with a "#group" suffix, the common metadata of the
+// declaration, all names in "entity_names" and the replaced chunk IDs in "grouped_ids".
+func groupSmallSpecs(chunks []ChromaDocument, specs []*ast.ValueSpec, genDecl *ast.GenDecl, info *types.Info, common map[string]interface{}, maxTokens int, text func(first, last *ast.ValueSpec) string) []ChromaDocument {
+	grouped := make([]ChromaDocument, 0, len(chunks))
+	for i := 0; i < len(chunks); {
+		j, total := i, 0
+		for j < len(chunks) {
+			tokens := EstimateTokens(text(specs[j], specs[j]))
+			if tokens >= maxTokens || total+tokens > DefaultTokenLimit {
+				break
+			}
+			total += tokens
+			j++
+		}
+		if j-i < 2 {
+			grouped = append(grouped, chunks[i])
+			i++
+			continue
+		}
+		grouped = append(grouped, combineSpecChunks(chunks[i:j], genDecl, info, common, text(specs[i], specs[j-1])))
+		i = j
+	}
+	return grouped
+}
+
+// combineSpecChunks builds the chunk standing for a run of spec chunks.
+func combineSpecChunks(run []ChromaDocument, genDecl *ast.GenDecl, info *types.Info, common map[string]interface{}, text string) ChromaDocument {
+	metadata := make(map[string]interface{}, len(common)+8)
+	for k, v := range common {
+		metadata[k] = v
+	}
+	first, last := run[0].Metadata, run[len(run)-1].Metadata
+	for _, key := range []string{"entity_type", "declaration_kind", "declaration_order", "start_line"} {
+		metadata[key] = first[key]
+	}
+	metadata["end_line"] = last["end_line"]
+	setLanguageFloor(metadata, genDecl, info)
+
+	var names, ids, directives []string
+	seenDirectives := make(map[string]bool)
+	for _, chunk := range run {
+		name, _ := chunk.Metadata["entity_name"].(string)
+		names = append(names, strings.Split(name, ", ")...)
+		ids = append(ids, chunk.ID)
+		specDirectives, _ := chunk.Metadata["directives"].([]string)
+		for _, directive := range specDirectives {
+			if !seenDirectives[directive] {
+				seenDirectives[directive] = true
+				directives = append(directives, directive)
+			}
+		}
+	}
+	metadata["entity_name"] = strings.Join(names, ", ")
+	metadata["entity_names"] = names
+	metadata["grouped_ids"] = ids
+	if len(directives) > 0 {
+		metadata["directives"] = directives
+	}
+	return ChromaDocument{ID: run[0].ID + "#group", Document: text, Metadata: metadata}
+}
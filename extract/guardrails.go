@@ -0,0 +1,155 @@
+package extract
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QualityThresholds configure the heuristics that keep low-value chunks out of the
+// embedding model. Flagged chunks are still emitted, with "embedding_skipped" set so the
+// uploader stores them for lookup by ID without embedding them.
+type QualityThresholds struct {
+	// MinBlobLength is the length (in bytes) from which a string literal is checked for
+	// being an encoded blob.
+	MinBlobLength int
+	// MaxLiteralEntropy is the Shannon entropy, in bits per byte, above which a long
+	// literal is treated as encoded data rather than text. Prose sits around 4.
+	MaxLiteralEntropy float64
+	// MaxBlobRatio is the share of a chunk's bytes that may be blob literals.
+	MaxBlobRatio float64
+	// MaxLiteralTokenRatio is the share of literal tokens (among identifiers, keywords and
+	// literals) above which a chunk of at least MinTableTokens such tokens is treated as a
+	// generated table.
+	MaxLiteralTokenRatio float64
+	MinTableTokens       int
+}
+
+var DefaultQualityThresholds = QualityThresholds{
+	MinBlobLength:        128,
+	MaxLiteralEntropy:    5.0,
+	MaxBlobRatio:         0.5,
+	MaxLiteralTokenRatio: 0.75,
+	MinTableTokens:       100,
+}
+
+// Reasons recorded in "embedding_skip_reason".
+const (
+	skipReasonBinaryLiteral  = "binary_literal"
+	skipReasonEncodedBlob    = "encoded_blob"
+	skipReasonGeneratedTable = "generated_table"
+)
+
+// flagLowQualityChunks marks chunks whose text is dominated by generated tables, encoded
+// blobs or binary string literals and returns how many were flagged.
+func flagLowQualityChunks(chunks []ChromaDocument, thresholds QualityThresholds) int {
+	flagged := 0
+	for i := range chunks {
+		reason, detail := chunkQualityIssue(chunks[i].Document, thresholds)
+		if reason == "" {
+			continue
+		}
+		chunks[i].Metadata["embedding_skipped"] = true
+		chunks[i].Metadata["embedding_skip_reason"] = reason
+		chunks[i].Metadata["embedding_skip_detail"] = detail
+		flagged++
+	}
+	return flagged
+}
+
+// chunkQualityIssue tokenizes text and returns the reason it should not be embedded, or
+// "" if it looks like ordinary code.
+func chunkQualityIssue(text string, thresholds QualityThresholds) (reason, detail string) {
+	if len(text) == 0 {
+		return "", ""
+	}
+	src := []byte(text)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0) // Chunks are fragments; tolerate scan errors.
+
+	totalTokens, literalTokens := 0, 0
+	binaryBytes, blobBytes := 0, 0
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok.IsOperator() {
+			continue // Punctuation says nothing about how table-like a chunk is.
+		}
+		totalTokens++
+		switch tok {
+		case token.INT, token.FLOAT, token.IMAG, token.CHAR:
+			literalTokens++
+		case token.STRING:
+			literalTokens++
+			value, err := strconv.Unquote(lit)
+			if err != nil {
+				value = lit
+			}
+			switch {
+			case isBinaryString(value):
+				binaryBytes += len(lit)
+			case len(value) >= thresholds.MinBlobLength && shannonEntropy(value) > thresholds.MaxLiteralEntropy:
+				blobBytes += len(lit)
+			}
+		}
+	}
+
+	if ratio := float64(binaryBytes) / float64(len(text)); binaryBytes > 0 && ratio > thresholds.MaxBlobRatio {
+		return skipReasonBinaryLiteral, fmt.Sprintf("%.0f%% of the chunk is binary string literals", ratio*100)
+	}
+	if ratio := float64(binaryBytes+blobBytes) / float64(len(text)); blobBytes > 0 && ratio > thresholds.MaxBlobRatio {
+		return skipReasonEncodedBlob, fmt.Sprintf("%.0f%% of the chunk is high-entropy string literals", ratio*100)
+	}
+	if totalTokens >= thresholds.MinTableTokens {
+		if ratio := float64(literalTokens) / float64(totalTokens); ratio > thresholds.MaxLiteralTokenRatio {
+			return skipReasonGeneratedTable, fmt.Sprintf("%.0f%% of %d tokens are literals", ratio*100, totalTokens)
+		}
+	}
+	return "", ""
+}
+
+// isBinaryString reports whether a decoded literal is mostly non-printable bytes or
+// invalid UTF-8, as produced by embedding files with tools like go-bindata.
+func isBinaryString(value string) bool {
+	if len(value) < 16 {
+		return false
+	}
+	nonPrintable := 0
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if (r == utf8.RuneError && size == 1) || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			nonPrintable++
+		}
+		i += size
+	}
+	return nonPrintable*4 > len(value)
+}
+
+// shannonEntropy returns the entropy of value in bits per byte.
+func shannonEntropy(value string) float64 {
+	if len(value) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(value); i++ {
+		counts[value[i]]++
+	}
+	entropy := 0.0
+	n := float64(len(value))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
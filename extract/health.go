@@ -0,0 +1,207 @@
+package extract
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// HealthThresholds define when a package is reported as oversized.
+type HealthThresholds struct {
+	MaxFiles  int
+	MaxLines  int
+	MaxChunks int
+}
+
+var DefaultHealthThresholds = HealthThresholds{MaxFiles: 50, MaxLines: 10000, MaxChunks: 500}
+
+// PackageStats are the per-package numbers gathered while extracting.
+type PackageStats struct {
+	Path       string   `json:"path"`
+	Name       string   `json:"name"`
+	Files      int      `json:"files"`
+	Lines      int      `json:"lines"`
+	Chunks     int      `json:"chunks"`
+	Imports    []string `json:"imports,omitempty"`
+	ImportedBy int      `json:"imported_by"`
+	Errors     []string `json:"errors,omitempty"`
+	Issues     []string `json:"issues,omitempty"`
+	// Skipped marks packages that were loaded but not processed because the time budget
+	// ran out; they are left out of the health report.
+	Skipped bool `json:"skipped,omitempty"`
+	// FileHashes maps the processed source files to their SHA-256, for the manifest.
+	FileHashes map[string]string `json:"-"`
+}
+
+// HealthReport is a lightweight architecture lint over the loaded package graph.
+type HealthReport struct {
+	GeneratedAt        time.Time        `json:"generated_at"`
+	Thresholds         HealthThresholds `json:"thresholds"`
+	TotalPackages      int              `json:"total_packages"`
+	PackagesWithErrors int              `json:"packages_with_errors"`
+	// ImportCycles lists each strongly connected set of loaded packages; CycleErrors holds
+	// the go command's own "import cycle not allowed" diagnostics verbatim.
+	ImportCycles [][]string     `json:"import_cycles"`
+	CycleErrors  []string       `json:"cycle_errors,omitempty"`
+	Oversized    []PackageStats `json:"oversized"`
+	Packages     []PackageStats `json:"packages"`
+}
+
+// newPackageStats records the load-time facts about a package; lines and chunk counts are
+// filled in while its files are chunked.
+func newPackageStats(pkg *packages.Package) PackageStats {
+	stats := PackageStats{Path: pkg.PkgPath, Name: pkg.Name, Files: len(pkg.GoFiles)}
+	for importPath := range pkg.Imports {
+		stats.Imports = append(stats.Imports, importPath)
+	}
+	sort.Strings(stats.Imports)
+	for _, pkgErr := range pkg.Errors {
+		stats.Errors = append(stats.Errors, pkgErr.Error())
+	}
+	return stats
+}
+
+// BuildHealthReport flags import cycles, oversized packages and packages with errors.
+func BuildHealthReport(all []PackageStats, thresholds HealthThresholds) HealthReport {
+	stats := make([]PackageStats, 0, len(all))
+	for _, s := range all {
+		if !s.Skipped {
+			stats = append(stats, s)
+		}
+	}
+	report := HealthReport{
+		GeneratedAt:   time.Now().UTC(),
+		Thresholds:    thresholds,
+		TotalPackages: len(stats),
+		ImportCycles:  [][]string{},
+		Oversized:     []PackageStats{},
+	}
+
+	loaded := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		loaded[s.Path] = true
+	}
+	importedBy := make(map[string]int)
+	graph := make(map[string][]string)
+	for _, s := range stats {
+		for _, imp := range s.Imports {
+			if loaded[imp] {
+				importedBy[imp]++
+				graph[s.Path] = append(graph[s.Path], imp)
+			}
+		}
+	}
+	report.ImportCycles = append(report.ImportCycles, findImportCycles(graph)...)
+
+	seenCycleErrors := make(map[string]bool)
+	for _, s := range stats {
+		s.ImportedBy = importedBy[s.Path]
+		if len(s.Errors) > 0 {
+			report.PackagesWithErrors++
+			s.Issues = append(s.Issues, "has load or type errors")
+		}
+		for _, msg := range s.Errors {
+			if strings.Contains(msg, "import cycle not allowed") && !seenCycleErrors[msg] {
+				seenCycleErrors[msg] = true
+				report.CycleErrors = append(report.CycleErrors, msg)
+			}
+		}
+
+		oversized := false
+		if thresholds.MaxFiles > 0 && s.Files > thresholds.MaxFiles {
+			s.Issues = append(s.Issues, "too many files")
+			oversized = true
+		}
+		if thresholds.MaxLines > 0 && s.Lines > thresholds.MaxLines {
+			s.Issues = append(s.Issues, "too many lines")
+			oversized = true
+		}
+		if thresholds.MaxChunks > 0 && s.Chunks > thresholds.MaxChunks {
+			s.Issues = append(s.Issues, "too many declarations")
+			oversized = true
+		}
+		if oversized {
+			report.Oversized = append(report.Oversized, s)
+		}
+		report.Packages = append(report.Packages, s)
+	}
+
+	sort.Slice(report.Oversized, func(i, j int) bool { return report.Oversized[i].Lines > report.Oversized[j].Lines })
+	return report
+}
+
+// findImportCycles returns the strongly connected components with more than one package
+// (or a self-import), using Tarjan's algorithm. Each cycle and the list are sorted.
+func findImportCycles(graph map[string][]string) [][]string {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var strongConnect func(node string)
+	strongConnect = func(node string) {
+		indices[node] = index
+		lowlink[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		selfLoop := false
+		for _, next := range graph[node] {
+			if next == node {
+				selfLoop = true
+			}
+			if _, visited := indices[next]; !visited {
+				strongConnect(next)
+				if lowlink[next] < lowlink[node] {
+					lowlink[node] = lowlink[next]
+				}
+			} else if onStack[next] && indices[next] < lowlink[node] {
+				lowlink[node] = indices[next]
+			}
+		}
+
+		if lowlink[node] == indices[node] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoop {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	nodes := make([]string, 0, len(graph))
+	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if _, visited := indices[node]; !visited {
+			strongConnect(node)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// contentHash returns the hex SHA-256 of data.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
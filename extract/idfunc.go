@@ -0,0 +1,97 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"log"
+	"path/filepath"
+	"text/template"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// IDFunc computes the ID of a declaration's chunk. decl is the *ast.FuncDecl,
+// *ast.TypeSpec or *ast.ValueSpec the chunk was cut from, and meta its metadata, which
+// includes "default_id", the ID the ID scheme would have assigned. References between
+// chunks (receiver_type_id, referenced_type_ids, describe results) keep using default
+// IDs. API digest chunks are not passed to IDFunc.
+type IDFunc func(pkg *packages.Package, decl ast.Node, meta ChunkMetadata) string
+
+// IDTemplateData is what an -id-template is executed with.
+type IDTemplateData struct {
+	Package       string // Import path of the package.
+	PackageName   string
+	Entity        string // entity_name, e.g. "Server" or "*Server.Start".
+	EntityType    string
+	QualifiedName string
+	File          string // File name without directory.
+	FilePath      string
+	StartLine     int
+	EndLine       int
+	DefaultID     string
+	Meta          ChunkMetadata
+}
+
+// TemplateIDFunc returns an IDFunc that executes a text/template such as
+// "{{.Package}}:{{.Entity}}".
+func TemplateIDFunc(text string) (IDFunc, error) {
+	tmpl, err := template.New("id").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID template: %w", err)
+	}
+	return func(pkg *packages.Package, decl ast.Node, meta ChunkMetadata) string {
+		defaultID, _ := meta.Extra["default_id"].(string)
+		data := IDTemplateData{
+			Package:       packageImportPath(pkg),
+			PackageName:   meta.PackageName,
+			Entity:        meta.EntityName,
+			EntityType:    meta.EntityType,
+			QualifiedName: meta.QualifiedName,
+			File:          filepath.Base(meta.FilePath),
+			FilePath:      meta.FilePath,
+			StartLine:     meta.StartLine,
+			EndLine:       meta.EndLine,
+			DefaultID:     defaultID,
+			Meta:          meta,
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			log.Printf("Warning: ID template failed for %s: %v. Using the default ID.", defaultID, err)
+			return defaultID
+		}
+		return b.String()
+	}, nil
+}
+
+// customChunkIDs applies an IDFunc and keeps the resulting IDs unique within a run:
+// a repeated ID gets a "#n" suffix and a warning, since vector stores would otherwise
+// silently overwrite one chunk with another.
+type customChunkIDs struct {
+	fn   IDFunc
+	seen map[string]int
+}
+
+func newCustomChunkIDs(fn IDFunc) *customChunkIDs {
+	return &customChunkIDs{fn: fn, seen: make(map[string]int)}
+}
+
+// id returns the custom ID of a chunk whose metadata already holds its default_id.
+func (c *customChunkIDs) id(pkg *packages.Package, decl ast.Node, metadata map[string]interface{}) string {
+	defaultID, _ := metadata["default_id"].(string)
+	meta, err := ChromaDocument{ID: defaultID, Metadata: metadata}.TypedMetadata()
+	if err != nil {
+		log.Printf("Warning: %v. Using the default ID.", err)
+		return defaultID
+	}
+	id := c.fn(pkg, decl, meta)
+	if id == "" {
+		return defaultID
+	}
+	c.seen[id]++
+	if n := c.seen[id]; n > 1 {
+		log.Printf("Warning: custom ID %q is not unique (chunk %s); using %s#%d.", id, defaultID, id, n)
+		id = fmt.Sprintf("%s#%d", id, n)
+	}
+	return id
+}
@@ -0,0 +1,100 @@
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// InterfaceMethod describes one method declared by an interface type chunk.
+type InterfaceMethod struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+}
+
+// interfaceMembers returns the methods an interface declares and the interfaces it
+// embeds. Type set terms of constraints (~int | ~string) are neither.
+func interfaceMembers(iface *ast.InterfaceType, info *types.Info) ([]InterfaceMethod, []string) {
+	var methods []InterfaceMethod
+	var embedded []string
+	for _, field := range iface.Methods.List {
+		if funcType, isFunc := field.Type.(*ast.FuncType); isFunc {
+			for _, name := range field.Names {
+				methods = append(methods, InterfaceMethod{Name: name.Name, Signature: getSignature(funcType, info)})
+			}
+			continue
+		}
+		switch field.Type.(type) {
+		case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+			embedded = append(embedded, getTypeString(field.Type, info))
+		}
+	}
+	return methods, embedded
+}
+
+// setInterfaceMetadata records the declared methods of an interface as
+// "interface_methods" and "method_names" and its embedded interfaces as
+// "embedded_interfaces".
+func setInterfaceMetadata(metadata map[string]interface{}, iface *ast.InterfaceType, info *types.Info) {
+	methods, embedded := interfaceMembers(iface, info)
+	if len(methods) > 0 {
+		names := make([]string, len(methods))
+		for i, method := range methods {
+			names[i] = method.Name
+		}
+		metadata["interface_methods"] = methods
+		metadata["method_names"] = names
+	}
+	if len(embedded) > 0 {
+		metadata["embedded_interfaces"] = embedded
+	}
+}
+
+// interfaceMethodChunks returns a child chunk per method declared by the interface of
+// parent, so a query for a method signature finds the interface defining it. The text is
+// the method, with its doc comment when includeDocs is set, inside the interface's type
+// declaration; the chunk gets the ID <parent ID>.<method>, entity_type
+// "interface_method" and the parent's ID as "parent_id".
+func interfaceMethodChunks(parent ChromaDocument, typeSpec *ast.TypeSpec, iface *ast.InterfaceType, common map[string]interface{}, fset *token.FileSet, src string, info *types.Info, importPaths map[string]string, opts ExtractOptions) []ChromaDocument {
+	var children []ChromaDocument
+	for _, field := range iface.Methods.List {
+		funcType, isFunc := field.Type.(*ast.FuncType)
+		if !isFunc || len(field.Names) == 0 {
+			continue
+		}
+		from := field.Pos()
+		if opts.IncludeDocs && field.Doc != nil {
+			from = field.Doc.Pos()
+		}
+		to := field.End()
+		if field.Comment != nil {
+			to = field.Comment.End()
+		}
+		start, end := fset.Position(from).Offset, fset.Position(to).Offset
+		if start < 0 || end > len(src) || start > end {
+			continue
+		}
+		code := "type " + typeSpec.Name.Name + " interface {\n\t" + src[start:end] + "\n}"
+		code = rewriteQualifiers(code, field, info, importPaths, opts.QualifierRewrite)
+
+		name := field.Names[0].Name
+		metadata := make(map[string]interface{}, len(common)+10)
+		for k, v := range common {
+			metadata[k] = v
+		}
+		metadata["entity_type"] = "interface_method"
+		metadata["entity_name"] = typeSpec.Name.Name + "." + name
+		if qualifiedName, ok := parent.Metadata["qualified_name"].(string); ok {
+			metadata["qualified_name"] = qualifiedName + "." + name
+		}
+		metadata["receiver_type"] = typeSpec.Name.Name
+		metadata["signature"] = getSignature(funcType, info)
+		metadata["declaration_kind"] = "type"
+		metadata["start_line"] = fset.Position(from).Line
+		metadata["end_line"] = fset.Position(to).Line
+		metadata["parent_id"] = parent.ID
+		setDocMetadata(metadata, field.Doc)
+		children = append(children, ChromaDocument{ID: parent.ID + "." + name, Document: code, Metadata: metadata})
+	}
+	return children
+}
@@ -0,0 +1,54 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// usesIota reports whether a const declaration refers to iota. Its specs read only as a
+// whole then: a bare "Green" means nothing without the "Red Color = iota" above it.
+func usesIota(genDecl *ast.GenDecl) bool {
+	found := false
+	for _, spec := range genDecl.Specs {
+		ast.Inspect(spec, func(n ast.Node) bool {
+			if ident, isIdent := n.(*ast.Ident); isIdent && ident.Name == "iota" {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// setConstBlockMetadata describes a const declaration kept as one chunk: all names in
+// "entity_names" and, with type information, each constant's evaluated value in
+// "constant_values" (e.g. {"Red": "0", "Green": "1"}). It returns the entity name.
+func setConstBlockMetadata(metadata map[string]interface{}, genDecl *ast.GenDecl, info *types.Info) string {
+	var names []string
+	values := make(map[string]string)
+	for _, spec := range genDecl.Specs {
+		valueSpec, isValueSpec := spec.(*ast.ValueSpec)
+		if !isValueSpec {
+			continue
+		}
+		for _, name := range valueSpec.Names {
+			names = append(names, name.Name)
+			if info == nil || name.Name == "_" {
+				continue
+			}
+			if constObj, isConst := info.Defs[name].(*types.Const); isConst {
+				values[name.Name] = constObj.Val().ExactString()
+			}
+		}
+	}
+	entityName := strings.Join(names, ", ")
+	metadata["entity_type"] = "value_declaration"
+	metadata["entity_name"] = entityName
+	metadata["entity_names"] = names
+	metadata["declaration_kind"] = genDecl.Tok.String()
+	if len(values) > 0 {
+		metadata["constant_values"] = values
+	}
+	return entityName
+}
@@ -0,0 +1,164 @@
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"go/version"
+	"sort"
+	"strings"
+)
+
+// languageFeature names a language feature together with the Go release that introduced it.
+type languageFeature struct {
+	name      string
+	goVersion string
+}
+
+var (
+	featureTypeAlias     = languageFeature{"type_alias", "go1.9"}
+	featureNumberLiteral = languageFeature{"number_literal_syntax", "go1.13"}
+	featureGenerics      = languageFeature{"generics", "go1.18"}
+	featureSliceToArray  = languageFeature{"slice_to_array_conversion", "go1.20"}
+	featureMinMaxClear   = languageFeature{"min_max_clear_builtins", "go1.21"}
+	featureRangeOverInt  = languageFeature{"range_over_int", "go1.22"}
+	featureRangeOverFunc = languageFeature{"range_over_func", "go1.23"}
+	featureGenericAlias  = languageFeature{"generic_type_alias", "go1.24"}
+)
+
+// detectLanguageFeatures walks node and returns the names of version-gated language
+// features it uses along with the minimum Go version they require ("" if none were found).
+// Detection that depends on types (range-over-func, conversions, builtins) is skipped when
+// info is nil.
+func detectLanguageFeatures(node ast.Node, info *types.Info) (string, []string) {
+	found := make(map[languageFeature]bool)
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncType:
+			if x.TypeParams != nil && len(x.TypeParams.List) > 0 {
+				found[featureGenerics] = true
+			}
+		case *ast.TypeSpec:
+			hasTypeParams := x.TypeParams != nil && len(x.TypeParams.List) > 0
+			if x.Assign.IsValid() {
+				found[featureTypeAlias] = true
+				if hasTypeParams {
+					found[featureGenericAlias] = true
+				}
+			}
+			if hasTypeParams {
+				found[featureGenerics] = true
+			}
+		case *ast.IndexListExpr:
+			found[featureGenerics] = true // Explicit multi-argument instantiation, e.g. Pair[K, V].
+		case *ast.BasicLit:
+			if usesModernNumberLiteral(x) {
+				found[featureNumberLiteral] = true
+			}
+		case *ast.RangeStmt:
+			if info == nil {
+				break
+			}
+			if t := info.TypeOf(x.X); t != nil {
+				switch u := t.Underlying().(type) {
+				case *types.Signature:
+					found[featureRangeOverFunc] = true
+				case *types.Basic:
+					if u.Info()&types.IsInteger != 0 {
+						found[featureRangeOverInt] = true
+					}
+				}
+			}
+		case *ast.CallExpr:
+			if info == nil {
+				break
+			}
+			if ident, isIdent := ast.Unparen(x.Fun).(*ast.Ident); isIdent {
+				if builtin, isBuiltin := info.Uses[ident].(*types.Builtin); isBuiltin {
+					switch builtin.Name() {
+					case "min", "max", "clear":
+						found[featureMinMaxClear] = true
+					}
+				}
+			}
+			if isSliceToArrayConversion(x, info) {
+				found[featureSliceToArray] = true
+			}
+		}
+		return true
+	})
+
+	// Uses of generic functions or types with inferred or single type arguments.
+	if info != nil && !found[featureGenerics] {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if ident, isIdent := n.(*ast.Ident); isIdent {
+				if _, instantiated := info.Instances[ident]; instantiated {
+					found[featureGenerics] = true
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	minVersion := ""
+	var names []string
+	for feature := range found {
+		names = append(names, feature.name)
+		if minVersion == "" || version.Compare(feature.goVersion, minVersion) > 0 {
+			minVersion = feature.goVersion
+		}
+	}
+	sort.Strings(names)
+	return minVersion, names
+}
+
+// usesModernNumberLiteral reports binary/octal prefixes and digit separators (Go 1.13).
+func usesModernNumberLiteral(lit *ast.BasicLit) bool {
+	if lit.Kind != token.INT && lit.Kind != token.FLOAT && lit.Kind != token.IMAG {
+		return false
+	}
+	value := strings.ToLower(lit.Value)
+	return strings.Contains(value, "_") || strings.HasPrefix(value, "0b") || strings.HasPrefix(value, "0o")
+}
+
+// isSliceToArrayConversion reports conversions of a slice to an array type, e.g. [4]byte(s).
+func isSliceToArrayConversion(call *ast.CallExpr, info *types.Info) bool {
+	if len(call.Args) != 1 {
+		return false
+	}
+	tv, ok := info.Types[call.Fun]
+	if !ok || !tv.IsType() {
+		return false
+	}
+	if _, isArray := tv.Type.Underlying().(*types.Array); !isArray {
+		return false
+	}
+	argType := info.TypeOf(call.Args[0])
+	if argType == nil {
+		return false
+	}
+	_, isSlice := argType.Underlying().(*types.Slice)
+	return isSlice
+}
+
+// moduleGoVersion returns the module's go directive, normalized to the "go1.N" form used
+// by go/version, or "" when the package has no module information.
+func moduleGoVersion(goDirective string) string {
+	if goDirective == "" {
+		return ""
+	}
+	return "go" + goDirective
+}
+
+// setLanguageFloor records the language features used by node and the minimum Go
+// version they require. Chunks that use no version-gated feature get no floor.
+func setLanguageFloor(metadata map[string]interface{}, node ast.Node, info *types.Info) {
+	minVersion, features := detectLanguageFeatures(node, info)
+	if minVersion == "" {
+		return
+	}
+	metadata["min_go_version"] = minVersion
+	metadata["language_features"] = features
+}
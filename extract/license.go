@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// licenseNoAssertion is the SPDX value for "no license information could be determined".
+const licenseNoAssertion = "NOASSERTION"
+
+// spdxHeaderScanLimit bounds how far into a source file we look for an SPDX header.
+const spdxHeaderScanLimit = 4096
+
+// licenseFileNames are the per-directory license files recognized, in lookup order.
+var licenseFileNames = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "LICENCE", "COPYING", "COPYING.txt"}
+
+// licenseSignatures identify common licenses from distinctive phrases in their text.
+// More specific licenses come first (e.g. LGPL before GPL, BSD-3 before BSD-2).
+var licenseSignatures = []struct {
+	spdxID  string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 2.1"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+}
+
+// licenseInfo is the license that applies to a file and where it was found.
+type licenseInfo struct {
+	ID     string // SPDX identifier or licenseNoAssertion.
+	Source string // "spdx_header", the path of the LICENSE file, or "" if unknown.
+}
+
+// licenseDetector resolves the license of source files, caching per-directory lookups.
+type licenseDetector struct {
+	boundaries map[string]bool // Directories above which LICENSE lookup stops.
+	dirCache   map[string]licenseInfo
+}
+
+// newLicenseDetector creates a detector that searches for LICENSE files upwards from each
+// source file but never above any of the given boundary directories (project and module roots).
+func newLicenseDetector(boundaryDirs ...string) *licenseDetector {
+	d := &licenseDetector{boundaries: make(map[string]bool), dirCache: make(map[string]licenseInfo)}
+	for _, dir := range boundaryDirs {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			d.boundaries[abs] = true
+		}
+	}
+	return d
+}
+
+// addBoundary registers another directory (e.g. a module root) at which lookups stop.
+func (d *licenseDetector) addBoundary(dir string) {
+	if dir == "" {
+		return
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		d.boundaries[abs] = true
+	}
+}
+
+// licenseFor returns the license of a source file. An SPDX-License-Identifier header in
+// the file takes precedence over the nearest LICENSE file in its directory hierarchy.
+func (d *licenseDetector) licenseFor(filePath string, content []byte) licenseInfo {
+	if id := spdxIdentifier(content); id != "" {
+		return licenseInfo{ID: id, Source: "spdx_header"}
+	}
+	return d.directoryLicense(filepath.Dir(filePath))
+}
+
+// directoryLicense finds the nearest LICENSE file at or above dir, stopping at a boundary.
+func (d *licenseDetector) directoryLicense(dir string) licenseInfo {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if cached, ok := d.dirCache[dir]; ok {
+		return cached
+	}
+
+	result := licenseInfo{ID: licenseNoAssertion}
+	found := false
+	for _, name := range licenseFileNames {
+		candidate := filepath.Join(dir, name)
+		if text, err := ioutil.ReadFile(candidate); err == nil {
+			result = licenseInfo{ID: identifyLicense(text), Source: candidate}
+			found = true
+			break
+		}
+	}
+
+	if !found && !d.boundaries[dir] {
+		if parent := filepath.Dir(dir); parent != dir {
+			result = d.directoryLicense(parent)
+		}
+	}
+	d.dirCache[dir] = result
+	return result
+}
+
+// spdxIdentifier extracts the license expression from an SPDX-License-Identifier line
+// near the top of a file.
+func spdxIdentifier(content []byte) string {
+	if len(content) > spdxHeaderScanLimit {
+		content = content[:spdxHeaderScanLimit]
+	}
+	const marker = "SPDX-License-Identifier:"
+	idx := bytes.Index(content, []byte(marker))
+	if idx < 0 {
+		return ""
+	}
+	line := content[idx+len(marker):]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	id := strings.TrimSpace(string(line))
+	id = strings.TrimSpace(strings.TrimSuffix(id, "*/"))
+	return id
+}
+
+// identifyLicense matches LICENSE file text against known license signatures.
+func identifyLicense(text []byte) string {
+	normalized := strings.Join(strings.Fields(string(text)), " ")
+	for _, signature := range licenseSignatures {
+		matched := true
+		for _, phrase := range signature.phrases {
+			if !strings.Contains(normalized, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return signature.spdxID
+		}
+	}
+	return licenseNoAssertion
+}
@@ -0,0 +1,108 @@
+package extract
+
+import (
+	"log"
+	"strconv"
+)
+
+// Vector stores only apply range filters ($gt, $lte, ...) to numeric metadata and
+// equality on booleans is type-sensitive, so counters must always be emitted as JSON
+// numbers and flags as JSON booleans, never as strings.
+var (
+	integerMetadataFields = map[string]bool{
+		"start_line":         true,
+		"end_line":           true,
+		"declaration_order":  true,
+		"method_order":       true,
+		"method_set_size":    true,
+		"exported_constants": true,
+		"exported_variables": true,
+		"binary_size":        true,
+		"binary_instances":   true,
+		"chunk_index":        true,
+		"chunk_total":        true,
+		"seed_corpus_size":   true,
+		"declaration_count":  true,
+		// Set by metadata extractors (see RegisterExtractor).
+		"complexity": true,
+		"loc":        true,
+	}
+	booleanMetadataFields = map[string]bool{
+		"typed":             true,
+		"is_internal":       true,
+		"has_errors":        true,
+		"mutates_receiver":  true,
+		"encoding_repaired": true,
+		"embedding_skipped": true,
+		"module_local":      true,
+		"in_binary":         true,
+		"test_file":         true,
+		"vendored":          true,
+		"is_generic":        true,
+		"output_unordered":  true,
+		"uses_cgo":          true,
+		"generated":         true,
+	}
+)
+
+// enforceMetadataTypes coerces the known counter and flag fields of every chunk to int
+// and bool. Values that cannot be coerced are dropped with a warning rather than
+// emitted with the wrong JSON type.
+func enforceMetadataTypes(chunks []ChromaDocument) {
+	for i := range chunks {
+		for key, value := range chunks[i].Metadata {
+			var (
+				coerced interface{}
+				ok      bool
+			)
+			switch {
+			case integerMetadataFields[key]:
+				coerced, ok = coerceInt(value)
+			case booleanMetadataFields[key]:
+				coerced, ok = coerceBool(value)
+			default:
+				continue
+			}
+			if !ok {
+				log.Printf("Warning: dropping metadata %q=%v of chunk %s: not a valid %T.", key, value, chunks[i].ID, coerced)
+				delete(chunks[i].Metadata, key)
+				continue
+			}
+			chunks[i].Metadata[key] = coerced
+		}
+	}
+}
+
+func coerceInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func coerceBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, true
+		}
+	}
+	return false, false
+}
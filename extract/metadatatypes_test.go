@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnforceMetadataTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name:     "ints stay ints",
+			metadata: map[string]interface{}{"start_line": 3, "end_line": int64(9), "complexity": int32(4)},
+			want:     map[string]interface{}{"start_line": 3, "end_line": 9, "complexity": 4},
+		},
+		{
+			name:     "whole floats become ints",
+			metadata: map[string]interface{}{"loc": 12.0, "chunk_index": float64(2)},
+			want:     map[string]interface{}{"loc": 12, "chunk_index": 2},
+		},
+		{
+			name:     "numeric strings become ints",
+			metadata: map[string]interface{}{"start_line": "42", "loc": "7"},
+			want:     map[string]interface{}{"start_line": 42, "loc": 7},
+		},
+		{
+			name:     "invalid ints are dropped",
+			metadata: map[string]interface{}{"start_line": "forty", "complexity": 2.5, "end_line": true},
+			want:     map[string]interface{}{},
+		},
+		{
+			name:     "bools stay bools",
+			metadata: map[string]interface{}{"typed": true, "generated": false},
+			want:     map[string]interface{}{"typed": true, "generated": false},
+		},
+		{
+			name:     "bool strings become bools",
+			metadata: map[string]interface{}{"is_internal": "true", "test_file": "false", "uses_cgo": "1"},
+			want:     map[string]interface{}{"is_internal": true, "test_file": false, "uses_cgo": true},
+		},
+		{
+			name:     "invalid bools are dropped",
+			metadata: map[string]interface{}{"typed": "yes", "vendored": 1},
+			want:     map[string]interface{}{},
+		},
+		{
+			name:     "other fields are left alone",
+			metadata: map[string]interface{}{"entity_name": "42", "signature": "func()", "calls": []string{"fmt.Println"}},
+			want:     map[string]interface{}{"entity_name": "42", "signature": "func()", "calls": []string{"fmt.Println"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := []ChromaDocument{{ID: "chunk", Metadata: tt.metadata}}
+			enforceMetadataTypes(chunks)
+			if !reflect.DeepEqual(chunks[0].Metadata, tt.want) {
+				t.Errorf("enforceMetadataTypes() = %#v, want %#v", chunks[0].Metadata, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// namedTypeOf resolves a receiver or type-spec identifier to its *types.Named, dereferencing
+// pointers. It returns nil when type information is unavailable.
+func namedTypeOf(t types.Type) *types.Named {
+	if ptr, isPtr := t.(*types.Pointer); isPtr {
+		t = ptr.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// receiverNamedType returns the named receiver type of a method declaration.
+func receiverNamedType(funcDecl *ast.FuncDecl, info *types.Info) *types.Named {
+	if info == nil {
+		return nil
+	}
+	fn, isFunc := info.Defs[funcDecl.Name].(*types.Func)
+	if !isFunc {
+		return nil
+	}
+	sig, isSig := fn.Type().(*types.Signature)
+	if !isSig || sig.Recv() == nil {
+		return nil
+	}
+	return namedTypeOf(sig.Recv().Type())
+}
+
+// methodSetOf returns the full method set reachable from t, using the pointer method set
+// for concrete types so that pointer-receiver methods are included.
+func methodSetOf(t types.Type) *types.MethodSet {
+	if _, isPtr := t.(*types.Pointer); !isPtr && !types.IsInterface(t) {
+		t = types.NewPointer(t)
+	}
+	return types.NewMethodSet(t)
+}
+
+// embeddedMethods maps method names to the fully qualified methods (e.g. "(*pkg.Base).Name")
+// that the struct type would receive through its embedded fields, at any depth.
+func embeddedMethods(named *types.Named) map[string][]string {
+	methods := make(map[string][]string)
+	structType, isStruct := named.Underlying().(*types.Struct)
+	if !isStruct {
+		return methods
+	}
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+		methodSet := methodSetOf(field.Type())
+		for j := 0; j < methodSet.Len(); j++ {
+			if fn, isFunc := methodSet.At(j).Obj().(*types.Func); isFunc {
+				methods[fn.Name()] = append(methods[fn.Name()], fn.FullName())
+			}
+		}
+	}
+	return methods
+}
+
+// methodOverrides returns the embedded methods that the given method declaration shadows
+// because its receiver type declares a method with the same name.
+func methodOverrides(funcDecl *ast.FuncDecl, info *types.Info) []string {
+	named := receiverNamedType(funcDecl, info)
+	if named == nil {
+		return nil
+	}
+	overrides := embeddedMethods(named)[funcDecl.Name.Name]
+	sort.Strings(overrides)
+	return overrides
+}
+
+// typePromotion lists the methods a named type gains through embedding (promoted_methods)
+// and the names of its own methods that shadow an embedded method (overridden_methods).
+func typePromotion(typeSpec *ast.TypeSpec, info *types.Info) (promoted []string, overridden []string) {
+	if info == nil {
+		return nil, nil
+	}
+	typeName, isTypeName := info.Defs[typeSpec.Name].(*types.TypeName)
+	if !isTypeName {
+		return nil, nil
+	}
+	named := namedTypeOf(typeName.Type())
+	if named == nil {
+		return nil, nil
+	}
+	if _, isStruct := named.Underlying().(*types.Struct); !isStruct {
+		return nil, nil
+	}
+
+	// Selections with an index path longer than one are reached through an embedded field.
+	methodSet := methodSetOf(named)
+	for i := 0; i < methodSet.Len(); i++ {
+		selection := methodSet.At(i)
+		if len(selection.Index()) > 1 {
+			if fn, isFunc := selection.Obj().(*types.Func); isFunc {
+				promoted = append(promoted, fn.FullName())
+			}
+		}
+	}
+
+	embedded := embeddedMethods(named)
+	for i := 0; i < named.NumMethods(); i++ {
+		if name := named.Method(i).Name(); len(embedded[name]) > 0 {
+			overridden = append(overridden, name)
+		}
+	}
+
+	sort.Strings(promoted)
+	sort.Strings(overridden)
+	return promoted, overridden
+}
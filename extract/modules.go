@@ -0,0 +1,116 @@
+package extract
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// moduleMetadata describes the module a package was built from. With a replace
+// directive, module_path is still the path used in imports (and in qualified names),
+// while module_replacement records what the build actually used: another module
+// version, or a local directory for module_local replacements.
+func moduleMetadata(module *packages.Module) map[string]interface{} {
+	if module == nil {
+		return nil
+	}
+	metadata := map[string]interface{}{"module_path": module.Path}
+	if module.Version != "" {
+		metadata["module_version"] = module.Version
+	}
+	if replace := module.Replace; replace != nil {
+		if replace.Version != "" {
+			metadata["module_replacement"] = replace.Path + "@" + replace.Version
+		} else {
+			metadata["module_replacement"] = replace.Dir
+			metadata["module_local"] = true
+		}
+	}
+	return metadata
+}
+
+// packageImportPath returns the import path source code uses for pkg. Packages resolved
+// from a vendor directory outside module mode carry a ".../vendor/" prefix that is not
+// part of the import path; in module mode the package path is the import path, even if
+// it contains "/vendor/" itself.
+func packageImportPath(pkg *packages.Package) string {
+	if pkg.Module != nil {
+		return pkg.PkgPath
+	}
+	if i := strings.LastIndex(pkg.PkgPath, "/vendor/"); i >= 0 {
+		return pkg.PkgPath[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(pkg.PkgPath, "vendor/")
+}
+
+// sourceImportPaths maps the package paths of pkg's imports to the import paths its
+// source uses for them; they differ only for vendored packages outside module mode.
+func sourceImportPaths(pkg *packages.Package) map[string]string {
+	paths := make(map[string]string, len(pkg.Imports))
+	for path, imported := range pkg.Imports {
+		paths[imported.PkgPath] = path
+	}
+	return paths
+}
+
+// nestedModuleDirs returns the directories below projectPath that hold their own
+// go.mod. "./..." stops at module boundaries, so their packages are loaded separately.
+func nestedModuleDirs(projectPath string) []string {
+	var dirs []string
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != projectPath && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata") {
+			return filepath.SkipDir
+		}
+		if path != projectPath {
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				dirs = append(dirs, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: could not scan %s for nested modules: %v", projectPath, err)
+	}
+	return dirs
+}
+
+// loadNestedModules loads "./..." in every module nested below cfg.Dir that the initial
+// load did not already cover (as a go.work workspace does), and returns the extra packages.
+// It is used with ExtractOptions.NestedModules.
+func loadNestedModules(cfg *packages.Config, loaded []*packages.Package) []*packages.Package {
+	covered := make(map[string]bool)
+	for _, pkg := range loaded {
+		if pkg.Module != nil && pkg.Module.Dir != "" {
+			covered[filepath.Clean(pkg.Module.Dir)] = true
+		}
+	}
+
+	var extra []*packages.Package
+	for _, dir := range nestedModuleDirs(cfg.Dir) {
+		if covered[filepath.Clean(dir)] {
+			continue
+		}
+		nested := *cfg
+		nested.Dir = dir
+		nested.Env = workspaceEnv(cfg.Env, dir)
+		log.Printf("Loading nested module in %s...", dir)
+		pkgs, err := packages.Load(&nested, "./...")
+		if err != nil {
+			log.Printf("Warning: failed to load nested module %s: %v", dir, err)
+			continue
+		}
+		extra = append(extra, pkgs...)
+	}
+	return extra
+}
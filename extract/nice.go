@@ -0,0 +1,63 @@
+package extract
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultNiceIORate is the file read budget in --nice mode (bytes per second).
+const DefaultNiceIORate = 8 << 20
+
+// niceProcessPriority is the scheduling niceness applied in --nice mode on platforms that
+// support it (see lowerProcessPriority).
+const niceProcessPriority = 10
+
+// ioThrottle limits the rate at which source files are read. A nil *ioThrottle imposes no
+// limit, so callers can use it unconditionally.
+type ioThrottle struct {
+	mu          sync.Mutex
+	bytesPerSec int
+	start       time.Time
+	consumed    int64
+}
+
+func newIOThrottle(bytesPerSec int) *ioThrottle {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &ioThrottle{bytesPerSec: bytesPerSec, start: time.Now()}
+}
+
+// Wait accounts for n bytes read and sleeps long enough to keep the average rate since
+// the throttle was created at or below the configured budget.
+func (t *ioThrottle) Wait(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.consumed += int64(n)
+	due := t.start.Add(time.Duration(float64(t.consumed) / float64(t.bytesPerSec) * float64(time.Second)))
+	t.mu.Unlock()
+
+	if delay := time.Until(due); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// applyNiceMode limits this process to a single OS thread running Go code, lowers its
+// scheduling priority where possible, and returns the environment for go/packages so the
+// `go list` subprocesses it spawns also build with -p=1.
+func applyNiceMode() []string {
+	runtime.GOMAXPROCS(1)
+	if err := lowerProcessPriority(niceProcessPriority); err != nil {
+		log.Printf("Warning: could not lower process priority: %v", err)
+	}
+
+	env := os.Environ()
+	goflags := strings.TrimSpace(os.Getenv("GOFLAGS") + " -p=1")
+	return append(env, "GOFLAGS="+goflags)
+}
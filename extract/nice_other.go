@@ -0,0 +1,9 @@
+//go:build !unix
+
+package extract
+
+// lowerProcessPriority is a no-op on platforms without setpriority; --nice still limits
+// parallelism and I/O rate there.
+func lowerProcessPriority(niceness int) error {
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package extract
+
+import "syscall"
+
+// lowerProcessPriority raises the niceness of the current process (and hence of the
+// go list subprocesses it starts, which inherit it).
+func lowerProcessPriority(niceness int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, niceness)
+}
@@ -0,0 +1,124 @@
+package extract
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// normalizeChunkText optionally dedents a chunk and converts its leading tabs to
+// indentWidth spaces (indentWidth <= 0 keeps tabs).
+//
+// Chunks are sliced starting at the declaration itself, so their first line carries no
+// indentation while the remaining lines keep the indentation of the enclosing block (for
+// example a struct spec inside a grouped "type (...)" declaration). Dedenting removes that
+// inherited indentation from the second line onward so the chunk renders flush-left.
+// Lines continuing a raw string literal are text, not indentation, and are left as they are.
+func normalizeChunkText(text string, indentWidth int, dedent bool) string {
+	if indentWidth <= 0 && !dedent {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	code, raw := classifyLines(text, len(lines))
+
+	if dedent && len(lines) > 1 {
+		common := inheritedIndent(lines, code, raw)
+		if common != "" {
+			for i := 1; i < len(lines); i++ {
+				if !raw[i] {
+					lines[i] = strings.TrimPrefix(lines[i], common)
+				}
+			}
+		}
+	}
+
+	if indentWidth > 0 {
+		spaces := strings.Repeat(" ", indentWidth)
+		for i, line := range lines {
+			if raw[i] {
+				continue
+			}
+			trimmed := strings.TrimLeft(line, "\t")
+			if tabs := len(line) - len(trimmed); tabs > 0 {
+				lines[i] = strings.Repeat(spaces, tabs) + trimmed
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// classifyLines scans chunk text as Go tokens and reports which of its lines hold code
+// (anything but comments) and which continue a raw string literal started on an earlier
+// line.
+func classifyLines(text string, lineCount int) (code, raw []bool) {
+	code, raw = make([]bool, lineCount), make([]bool, lineCount)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(text))
+	var s scanner.Scanner
+	s.Init(file, []byte(text), nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.COMMENT || tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		line := file.Line(pos) - 1
+		code[line] = true
+		if tok == token.STRING && strings.HasPrefix(lit, "`") {
+			for k := 1; k <= strings.Count(lit, "\n") && line+k < lineCount; k++ {
+				code[line+k], raw[line+k] = true, true
+			}
+		}
+	}
+	return code, raw
+}
+
+// inheritedIndent returns the indentation shared by the lines after the first. Doc
+// comments and directives prepended to a chunk start flush-left like the declaration's
+// first line, so it is taken from the code lines after that line, or for a one-line
+// declaration from the comment lines above it.
+func inheritedIndent(lines []string, code, raw []bool) string {
+	first := 0
+	for first < len(lines) && !code[first] {
+		first++
+	}
+	var indented []string
+	for i := first + 1; i < len(lines); i++ {
+		if code[i] && !raw[i] {
+			indented = append(indented, lines[i])
+		}
+	}
+	if len(indented) == 0 {
+		for i := 1; i < first; i++ {
+			indented = append(indented, lines[i])
+		}
+	}
+	return commonIndent(indented)
+}
+
+// commonIndent returns the longest whitespace prefix shared by all non-blank lines.
+func commonIndent(lines []string) string {
+	common := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common = indent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(indent, common) {
+			common = common[:len(common)-1]
+		}
+		if common == "" {
+			break
+		}
+	}
+	return common
+}
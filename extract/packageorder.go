@@ -0,0 +1,164 @@
+package extract
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Package processing orders for -package-order.
+const (
+	PackageOrderLoad     = "load"     // As returned by go/packages.
+	PackageOrderRecency  = "recency"  // Most recently committed (or modified) first.
+	PackageOrderPriority = "priority" // Packages matching the priority list first, in list order.
+)
+
+func validatePackageOrder(order string) error {
+	switch order {
+	case PackageOrderLoad, PackageOrderRecency, PackageOrderPriority:
+		return nil
+	}
+	return fmt.Errorf("unknown package order %q (want %s, %s or %s)", order, PackageOrderLoad, PackageOrderRecency, PackageOrderPriority)
+}
+
+// LoadPriorityList reads one package pattern per line; blank lines and # comments are
+// ignored. A pattern is an import path, optionally ending in "/..." to match a subtree.
+func LoadPriorityList(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read priority list: %w", err)
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
+
+// orderPackages returns pkgs in the order they should be processed, so that time-boxed
+// and streaming runs index the most relevant code first. The sort is stable: packages
+// that tie keep their load order.
+func orderPackages(pkgs []*packages.Package, projectPath, order string, priorities []string) []*packages.Package {
+	ordered := append([]*packages.Package(nil), pkgs...)
+	switch order {
+	case PackageOrderRecency:
+		recency := packageRecency(ordered, projectPath)
+		sort.SliceStable(ordered, func(i, j int) bool { return recency[ordered[i]] > recency[ordered[j]] })
+	case PackageOrderPriority:
+		rank := func(pkg *packages.Package) int {
+			for i, pattern := range priorities {
+				if matchPackagePattern(pattern, pkg.PkgPath) {
+					return i
+				}
+			}
+			return len(priorities)
+		}
+		sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+	}
+	return ordered
+}
+
+func matchPackagePattern(pattern, pkgPath string) bool {
+	if prefix, isTree := strings.CutSuffix(pattern, "/..."); isTree {
+		return pkgPath == prefix || strings.HasPrefix(pkgPath, prefix+"/")
+	}
+	return pkgPath == pattern
+}
+
+// packageDir returns the directory holding a package's sources.
+func packageDir(pkg *packages.Package) string {
+	if len(pkg.GoFiles) > 0 {
+		return filepath.Dir(pkg.GoFiles[0])
+	}
+	if len(pkg.CompiledGoFiles) > 0 {
+		return filepath.Dir(pkg.CompiledGoFiles[0])
+	}
+	return ""
+}
+
+// packageRecency returns the Unix time each package last changed: its latest commit
+// touching the package directory, or the newest source modification time for packages
+// git does not know about (or when projectPath is not a repository).
+func packageRecency(pkgs []*packages.Package, projectPath string) map[*packages.Package]int64 {
+	dirs := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if dir := packageDir(pkg); dir != "" {
+			dirs[dir] = true
+		}
+	}
+	commitTimes, err := gitDirectoryTimes(projectPath, dirs)
+	if err != nil {
+		log.Printf("Warning: git recency unavailable (%v); using file modification times.", err)
+	}
+
+	recency := make(map[*packages.Package]int64, len(pkgs))
+	for _, pkg := range pkgs {
+		if t, ok := commitTimes[packageDir(pkg)]; ok {
+			recency[pkg] = t
+			continue
+		}
+		for _, file := range pkg.GoFiles {
+			if info, err := os.Stat(file); err == nil && info.ModTime().Unix() > recency[pkg] {
+				recency[pkg] = info.ModTime().Unix()
+			}
+		}
+	}
+	return recency
+}
+
+// gitDirectoryTimes walks the git log of projectPath from newest to oldest and records the
+// commit time at which each of dirs was last touched. It stops reading once every
+// directory has been seen.
+func gitDirectoryTimes(projectPath string, dirs map[string]bool) (map[string]int64, error) {
+	top, err := exec.Command("git", "-C", projectPath, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository")
+	}
+	root := strings.TrimSpace(string(top))
+
+	cmd := exec.Command("git", "-C", projectPath, "log", "--format=@%ct", "--name-only", "--no-renames", "--", ".")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	times := make(map[string]int64)
+	var commitTime int64
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(times) < len(dirs) {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "@") {
+			commitTime, _ = strconv.ParseInt(line[1:], 10, 64)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		dir := filepath.Join(root, filepath.Dir(line))
+		if _, seen := times[dir]; !seen && dirs[dir] {
+			times[dir] = commitTime
+		}
+	}
+	return times, nil
+}
@@ -0,0 +1,107 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PathFilter selects source files by glob patterns matched against their slash-separated
+// path relative to the project root. "*" and "?" stay within one path element, "**"
+// matches any number of elements, and a pattern without a "/" matches the file name in
+// any directory. Exclude patterns win over include patterns; with no include patterns
+// every file not excluded is kept.
+type PathFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// validate reports the first malformed pattern.
+func (f PathFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		for _, element := range strings.Split(pattern, "/") {
+			if element == "**" {
+				continue
+			}
+			if _, err := path.Match(element, ""); err != nil {
+				return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keeps reports whether the file at filePath, inside root, passes the filter.
+func (f PathFilter) keeps(root, filePath string) bool {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return true
+	}
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil {
+		rel = filePath
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range f.Exclude {
+		if matchPathGlob(pattern, rel) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if matchPathGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFiles returns the files of a package, inside root, that pass the filter.
+func (f PathFilter) filterFiles(fset *token.FileSet, root string, files []*ast.File) []*ast.File {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return files
+	}
+	var kept []*ast.File
+	for _, file := range files {
+		if tokFile := fset.File(file.Pos()); tokFile == nil || f.keeps(root, tokFile.Name()) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// matchPathGlob matches a slash-separated relative path against a glob pattern that
+// may contain "**" elements.
+func matchPathGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchGlobElements(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchGlobElements(pattern, elements []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" absorbs zero or more elements; try every split point.
+			for skip := 0; skip <= len(elements); skip++ {
+				if matchGlobElements(pattern[1:], elements[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elements) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+			return false
+		}
+		pattern, elements = pattern[1:], elements[1:]
+	}
+	return len(elements) == 0
+}
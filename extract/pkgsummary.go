@@ -0,0 +1,167 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// buildPackageSummary produces a synthetic chunk giving a high-level entry point to a
+// package: its doc comment, its imports and the signatures of its exported symbols in
+// source order. It returns false if the package has neither a doc comment nor exported
+// symbols.
+func buildPackageSummary(pkg *packages.Package, files []*ast.File, info *types.Info, typed bool) (ChromaDocument, bool) {
+	var doc *ast.CommentGroup
+	var lines, names []string
+	importSet := make(map[string]bool)
+
+	for _, file := range files {
+		if doc == nil && file.Doc != nil {
+			doc = file.Doc
+		}
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				importSet[path] = true
+			}
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if line, ok := summaryFuncLine(decl, info); ok {
+					lines = append(lines, line)
+					name := decl.Name.Name
+					if decl.Recv != nil && len(decl.Recv.List) > 0 {
+						name = receiverBaseTypeName(decl.Recv.List[0].Type) + "." + name
+					}
+					names = append(names, name)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							lines = append(lines, summaryTypeLine(spec, info))
+							names = append(names, spec.Name.Name)
+						}
+					case *ast.ValueSpec:
+						for i, name := range spec.Names {
+							if name.IsExported() {
+								lines = append(lines, decl.Tok.String()+" "+digestLine(decl.Tok, spec, i, pkg.Types, info))
+								names = append(names, name.Name)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if doc == nil && len(names) == 0 {
+		return ChromaDocument{}, false
+	}
+	imports := make([]string, 0, len(importSet))
+	for path := range importSet {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Summary of package %s (%s)\n", pkg.Name, pkg.PkgPath)
+	if doc != nil {
+		b.WriteString("//\n")
+		for _, line := range strings.Split(strings.TrimSpace(doc.Text()), "\n") {
+			b.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+		}
+	}
+	fmt.Fprintf(&b, "package %s\n", pkg.Name)
+	if len(imports) > 0 {
+		b.WriteString("\nimport (\n")
+		for _, path := range imports {
+			b.WriteString("\t" + strconv.Quote(path) + "\n")
+		}
+		b.WriteString(")\n")
+	}
+	if len(lines) > 0 {
+		b.WriteString("\n// Exported symbols\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	packageDir := ""
+	if len(pkg.GoFiles) > 0 {
+		packageDir = filepath.Dir(pkg.GoFiles[0])
+	}
+	metadata := map[string]interface{}{
+		"file_path":    packageDir,
+		"package_name": pkg.Name,
+		"package_path": pkg.PkgPath,
+		"entity_type":  "package_summary",
+		"entity_name":  pkg.Name + ".package_summary",
+		"typed":        typed,
+		"imports":      imports,
+		"symbols":      names,
+	}
+	setDocMetadata(metadata, doc)
+	return ChromaDocument{
+		ID:       fmt.Sprintf("%s:package_summary", pkg.PkgPath),
+		Document: b.String(),
+		Metadata: metadata,
+	}, true
+}
+
+// summaryFuncLine renders the signature of an exported function, or of an exported
+// method of an exported type, e.g. "func (*Server) Start(ctx context.Context) error".
+// Receivers are shown as written.
+func summaryFuncLine(funcDecl *ast.FuncDecl, info *types.Info) (string, bool) {
+	if !funcDecl.Name.IsExported() {
+		return "", false
+	}
+	line := "func "
+	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+		recv := funcDecl.Recv.List[0].Type
+		if !ast.IsExported(receiverBaseTypeName(recv)) {
+			return "", false
+		}
+		line += "(" + types.ExprString(recv) + ") "
+	}
+	return line + funcDecl.Name.Name + getSignature(funcDecl.Type, info), true
+}
+
+// typeParamList renders a type parameter list as written, e.g. "[K comparable, V any]".
+func typeParamList(typeParams *ast.FieldList) string {
+	if typeParams == nil || len(typeParams.List) == 0 {
+		return ""
+	}
+	var params []string
+	for _, field := range typeParams.List {
+		var names []string
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		params = append(params, strings.Join(names, ", ")+" "+types.ExprString(field.Type))
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// summaryTypeLine renders a type declaration by its kind, e.g. "type Server struct",
+// leaving fields and methods to the type's own chunk.
+func summaryTypeLine(typeSpec *ast.TypeSpec, info *types.Info) string {
+	line := "type " + typeSpec.Name.Name + typeParamList(typeSpec.TypeParams)
+	if typeSpec.Assign.IsValid() {
+		line += " ="
+	}
+	switch typeSpec.Type.(type) {
+	case *ast.StructType:
+		return line + " struct"
+	case *ast.InterfaceType:
+		return line + " interface"
+	}
+	return line + " " + getTypeString(typeSpec.Type, info)
+}
@@ -0,0 +1,265 @@
+// Package extract turns the packages of a Go module or workspace into chunks (one per
+// declaration by default) with metadata for vector stores. The command in the
+// repository root is a thin layer over it; other programs use a Processor.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+const (
+	// QualifierRewriteFull rewrites package qualifiers in chunk text to full import paths
+	// ("http.Get" becomes "net/http.Get"), so chunks are unambiguous out of context.
+	QualifierRewriteFull = "full"
+	// QualifierRewriteNone keeps chunk text exactly as written in the source.
+	QualifierRewriteNone = "none"
+)
+
+// validateQualifierRewrite checks the value of -qualifiers.
+func validateQualifierRewrite(mode string) error {
+	switch mode {
+	case "", QualifierRewriteFull, QualifierRewriteNone:
+		return nil
+	}
+	return fmt.Errorf("unknown qualifier rewrite mode %q (want %s or %s)", mode, QualifierRewriteFull, QualifierRewriteNone)
+}
+
+// rewriteQualifiers applies the qualifier rewrite mode to a chunk's text.
+func rewriteQualifiers(chunkCode string, node ast.Node, info *types.Info, importPaths map[string]string, mode string) string {
+	if mode == QualifierRewriteNone {
+		return chunkCode
+	}
+	return applyQualifierReplacements(chunkCode, node, info, importPaths)
+}
+
+// withDocComment prepends the declaration's doc comment, as written in src, to its chunk text.
+func withDocComment(chunkCode string, doc *ast.CommentGroup, fset *token.FileSet, src string) string {
+	if doc == nil {
+		return chunkCode
+	}
+	start, end := fset.Position(doc.Pos()).Offset, fset.Position(doc.End()).Offset
+	if start < 0 || end > len(src) || start > end {
+		return chunkCode
+	}
+	return src[start:end] + "\n" + chunkCode
+}
+
+// Processor runs the extraction pipeline with a fixed set of options, for use from
+// other Go code; the command line builds the same ExtractOptions from flags.
+type Processor struct {
+	opts ExtractOptions
+}
+
+// Option configures a Processor.
+type Option func(*ExtractOptions)
+
+// DefaultExtractOptions matches the command-line defaults.
+func DefaultExtractOptions() ExtractOptions {
+	return ExtractOptions{
+		PackageSummary:    true,
+		IncludeDocs:       true,
+		QualityThresholds: DefaultQualityThresholds,
+		InvalidUTF8:       InvalidUTF8Transcode,
+		PackageOrder:      PackageOrderLoad,
+		Vendor:            VendorSkip,
+		Generated:         GeneratedInclude,
+		QualifierRewrite:  QualifierRewriteFull,
+		IDScheme:          IDSchemeSymbol,
+		Granularity:       GranularityDecl,
+	}
+}
+
+// NewProcessor returns a Processor with the command-line defaults changed by options.
+func NewProcessor(options ...Option) *Processor {
+	p := &Processor{opts: DefaultExtractOptions()}
+	for _, option := range options {
+		option(&p.opts)
+	}
+	return p
+}
+
+// WithOptions replaces all options; later options still apply on top.
+func WithOptions(opts ExtractOptions) Option {
+	return func(o *ExtractOptions) { *o = opts }
+}
+
+// WithTests also extracts _test.go files.
+func WithTests() Option {
+	return func(o *ExtractOptions) { o.Tests = true }
+}
+
+// WithBuildTags loads packages with the given build tags.
+func WithBuildTags(tags ...string) Option {
+	return func(o *ExtractOptions) { o.BuildTags = append(o.BuildTags, tags...) }
+}
+
+// WithIncludeDocs prepends each declaration's doc comment to its chunk text (the default).
+func WithIncludeDocs() Option {
+	return func(o *ExtractOptions) { o.IncludeDocs = true }
+}
+
+// WithoutDocs leaves doc comments out of the chunk text.
+func WithoutDocs() Option {
+	return func(o *ExtractOptions) { o.IncludeDocs = false }
+}
+
+// WithFunctionSplitting splits functions over maxTokens estimated tokens into parts
+// overlapping by up to overlap estimated tokens.
+func WithFunctionSplitting(maxTokens, overlap int) Option {
+	return func(o *ExtractOptions) { o.SplitTokens, o.SplitOverlap = maxTokens, overlap }
+}
+
+// WithClosureChunks emits closures of at least minTokens estimated tokens as chunks.
+func WithClosureChunks(minTokens int) Option {
+	return func(o *ExtractOptions) { o.ClosureTokens = minTokens }
+}
+
+// WithEmbedAssets emits the text files embedded with //go:embed as chunks.
+func WithEmbedAssets() Option {
+	return func(o *ExtractOptions) { o.EmbedAssets = true }
+}
+
+// WithInterfaceMethodChunks emits a chunk per interface method.
+func WithInterfaceMethodChunks() Option {
+	return func(o *ExtractOptions) { o.InterfaceMethodChunks = true }
+}
+
+// WithSpecGrouping combines var and const specs under maxTokens estimated tokens.
+func WithSpecGrouping(maxTokens int) Option {
+	return func(o *ExtractOptions) { o.GroupTokens = maxTokens }
+}
+
+// WithGranularity selects GranularityDecl, GranularityFile, GranularityBoth or
+// GranularityType.
+func WithGranularity(granularity string) Option {
+	return func(o *ExtractOptions) { o.Granularity = granularity }
+}
+
+// WithQualifierRewrite selects QualifierRewriteFull or QualifierRewriteNone.
+func WithQualifierRewrite(mode string) Option {
+	return func(o *ExtractOptions) { o.QualifierRewrite = mode }
+}
+
+// WithIDFunc computes declaration chunk IDs with fn.
+func WithIDFunc(fn IDFunc) Option {
+	return func(o *ExtractOptions) { o.IDFunc = fn }
+}
+
+// WithPackages loads the given package patterns instead of every package.
+func WithPackages(patterns ...string) Option {
+	return func(o *ExtractOptions) { o.Packages = append(o.Packages, patterns...) }
+}
+
+// WithTargets extracts for the given "goos/goarch" targets.
+func WithTargets(targets ...string) Option {
+	return func(o *ExtractOptions) { o.Targets = append(o.Targets, targets...) }
+}
+
+// WithVendorPolicy selects VendorSkip, VendorInclude or VendorFirstParty.
+func WithVendorPolicy(policy string) Option {
+	return func(o *ExtractOptions) { o.Vendor = policy }
+}
+
+// WithNestedModules also extracts the modules nested below the project directory.
+func WithNestedModules() Option {
+	return func(o *ExtractOptions) { o.NestedModules = true }
+}
+
+// WithGeneratedPolicy selects GeneratedInclude or GeneratedSkip.
+func WithGeneratedPolicy(policy string) Option {
+	return func(o *ExtractOptions) { o.Generated = policy }
+}
+
+// Options returns the effective options.
+func (p *Processor) Options() ExtractOptions {
+	return p.opts
+}
+
+// Validate reports the first invalid option. Empty policy strings select the default
+// behavior.
+func (opts ExtractOptions) Validate() error {
+	checks := []error{
+		validateBuildTags(opts.BuildTags),
+		validateTargets(opts.Targets),
+		validateVendorPolicy(opts.Vendor),
+		validateGeneratedPolicy(opts.Generated),
+		validateQualifierRewrite(opts.QualifierRewrite),
+		validateIDScheme(opts.IDScheme),
+		validateGranularity(opts.Granularity),
+		validateFunctionSplitting(opts.SplitTokens, opts.SplitOverlap),
+		opts.Paths.validate(),
+	}
+	if opts.InvalidUTF8 != "" {
+		checks = append(checks, validateInvalidUTF8Policy(opts.InvalidUTF8))
+	}
+	if opts.PackageOrder != "" {
+		checks = append(checks, validatePackageOrder(opts.PackageOrder))
+	}
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract extracts one or more project roots into a single chunk set. Canceling ctx
+// stops the extraction; the chunks extracted so far are returned along with the error.
+func (p *Processor) Extract(ctx context.Context, projectPaths ...string) (*ExtractionResult, error) {
+	if err := p.opts.Validate(); err != nil {
+		return nil, err
+	}
+	if len(projectPaths) == 0 {
+		return nil, fmt.Errorf("no project path given")
+	}
+	roots := make([]string, 0, len(projectPaths))
+	for _, path := range projectPaths {
+		root, err := ValidateProjectPath(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return ExtractProjects(ctx, roots, p.opts)
+}
+
+// Process extracts a single project root and calls fn with each chunk as soon as its
+// package is finished, instead of collecting the whole chunk set in memory. Chunks of
+// entrypoint packages are not passed to fn. Streaming cannot merge chunks across
+// targets, so at most one target may be selected. An error from fn stops processing
+// and is returned.
+func (p *Processor) Process(ctx context.Context, projectPath string, fn func(ChromaDocument) error) error {
+	if err := p.opts.Validate(); err != nil {
+		return err
+	}
+	if len(p.opts.Targets) > 1 {
+		return fmt.Errorf("streaming supports a single target, got %d", len(p.opts.Targets))
+	}
+	root, err := ValidateProjectPath(projectPath)
+	if err != nil {
+		return err
+	}
+	opts := p.opts
+	if len(opts.Targets) == 1 {
+		opts.Target = opts.Targets[0]
+	}
+	target := opts.Target
+	if target == "" {
+		target = HostTarget()
+	}
+	name, id := ProjectNames([]string{root})[root], ProjectID(root)
+	_, err = StreamProject(ctx, root, opts, func(chunk ChromaDocument, entrypoint bool) error {
+		if entrypoint {
+			return nil
+		}
+		chunk.Metadata["project"] = name
+		chunk.Metadata["project_id"] = id
+		chunk.Metadata["targets"] = []string{target}
+		return fn(chunk)
+	})
+	return err
+}
@@ -0,0 +1,96 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ValidateProjectPath resolves path to an absolute directory and checks that it is the
+// root of a Go module or workspace, which go/packages needs to load "./...".
+func ValidateProjectPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("no project path given")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve project path %s: %w", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("project path %s: %w", abs, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("project path %s is not a directory", abs)
+	}
+	for _, name := range []string{"go.mod", "go.work"} {
+		if _, err := os.Stat(filepath.Join(abs, name)); err == nil {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("project path %s contains neither go.mod nor go.work", abs)
+}
+
+// ProjectNames returns the "project" metadata value of each root: its directory name,
+// or the full path where two roots share a directory name.
+func ProjectNames(projectPaths []string) map[string]string {
+	count := make(map[string]int)
+	for _, path := range projectPaths {
+		count[filepath.Base(path)]++
+	}
+	names := make(map[string]string, len(projectPaths))
+	for _, path := range projectPaths {
+		names[path] = filepath.Base(path)
+		if count[filepath.Base(path)] > 1 {
+			names[path] = path
+		}
+	}
+	return names
+}
+
+// ProjectID returns the "project_id" metadata value of a root: the module path declared
+// in its go.mod (none for a workspace root) and its absolute path. Unlike the project
+// name it tells apart checkouts that share a directory name.
+func ProjectID(root string) string {
+	return ProjectIDAt(root, root)
+}
+
+// ProjectIDAt is ProjectID for a checkout at root of the repository at location (a URL
+// or path), so that every checkout of the repository gets the same ID.
+func ProjectIDAt(root, location string) string {
+	modulePath, err := readModulePath(root)
+	if err != nil {
+		return location
+	}
+	return modulePath + "@" + location
+}
+
+// ExtractProjects extracts each root with its own package loader and combines the
+// results into one chunk set, tagging every chunk with the "project" it came from. When
+// ctx is canceled the chunks extracted so far are returned along with the error.
+func ExtractProjects(ctx context.Context, projectPaths []string, opts ExtractOptions) (*ExtractionResult, error) {
+	names := ProjectNames(projectPaths)
+	combined := &ExtractionResult{StartedAt: time.Now().UTC()}
+	for _, projectPath := range projectPaths {
+		result, err := extractProjectTargets(ctx, projectPath, opts)
+		id := ProjectID(projectPath)
+		if result == nil {
+			return nil, fmt.Errorf("project %s: %w", projectPath, err)
+		}
+		for _, chunks := range [][]ChromaDocument{result.Chunks, result.Entrypoints} {
+			for i := range chunks {
+				chunks[i].Metadata["project"] = names[projectPath]
+				chunks[i].Metadata["project_id"] = id
+			}
+		}
+		combined.Chunks = append(combined.Chunks, result.Chunks...)
+		combined.Entrypoints = append(combined.Entrypoints, result.Entrypoints...)
+		combined.Packages = append(combined.Packages, result.Packages...)
+		if err != nil {
+			return combined, fmt.Errorf("project %s: %w", projectPath, err)
+		}
+	}
+	return combined, nil
+}
@@ -0,0 +1,70 @@
+package extract
+
+import (
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTextTerms caps the number of distinct terms in search_text so very large
+// chunks do not produce unbounded metadata values.
+const maxSearchTextTerms = 512
+
+// buildSearchText produces a lowercase, space-separated bag of the identifiers and words
+// in text, each also split at camelCase, acronym and snake_case boundaries. For example
+// "EndpointsHandler.HTTPServe" yields "endpointshandler endpoints handler httpserve http
+// serve". Go keywords are dropped and terms are de-duplicated in first-seen order, which
+// makes plain substring ("$contains") matching work for partial identifiers.
+func buildSearchText(text string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if len(term) < 2 || seen[term] || len(terms) >= maxSearchTextTerms {
+			return
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if token.IsKeyword(word) {
+			continue
+		}
+		add(strings.ToLower(strings.Trim(word, "_")))
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			for _, part := range parts {
+				add(part)
+			}
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// splitIdentifier splits an identifier into lowercase words at underscores, lower-to-upper
+// transitions and the end of acronyms ("parseHTTPResponse" -> parse, http, response).
+func splitIdentifier(identifier string) []string {
+	var parts []string
+	for _, segment := range strings.Split(identifier, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+			// "HTTPResponse": split before the last capital of an acronym run.
+			if unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				boundary = true
+			}
+			if boundary {
+				parts = append(parts, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, strings.ToLower(string(runes[start:])))
+		}
+	}
+	return parts
+}
@@ -0,0 +1,61 @@
+package extract
+
+import (
+	"context"
+)
+
+// Sink is a destination for extracted chunks. Write may be called any number of times;
+// Flush makes everything written so far visible at the destination, and Close flushes
+// and releases the sink. New destinations implement Sink and need no change to the
+// traversal code.
+type Sink interface {
+	Write(ctx context.Context, chunks []ChromaDocument) error
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// Aborter is implemented by sinks that can discard what was written since the last
+// Flush instead of making it visible, such as files that are replaced atomically: after
+// a failed run their previous content is better than a partial chunk set.
+type Aborter interface {
+	Abort() error
+}
+
+// AbortSink releases a sink after a failed run: sinks implementing Aborter are aborted
+// and others, whose writes are already visible, are closed.
+func AbortSink(sink Sink) error {
+	if aborter, ok := sink.(Aborter); ok {
+		return aborter.Abort()
+	}
+	return sink.Close()
+}
+
+// defaultSinkBatchSize is how many chunks ProcessToSink passes to each Write.
+const defaultSinkBatchSize = 500
+
+// ProcessToSink streams the chunks of projectPath into sink, batchSize chunks per Write
+// (defaultSinkBatchSize if not positive), and closes the sink. On error the sink is
+// released with AbortSink, so a file sink keeps its previous content.
+func (p *Processor) ProcessToSink(ctx context.Context, projectPath string, sink Sink, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = defaultSinkBatchSize
+	}
+	batch := make([]ChromaDocument, 0, batchSize)
+	err := p.Process(ctx, projectPath, func(chunk ChromaDocument) error {
+		batch = append(batch, chunk)
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := sink.Write(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = sink.Write(ctx, batch)
+	}
+	if err != nil {
+		AbortSink(sink)
+		return err
+	}
+	return sink.Close()
+}
@@ -0,0 +1,165 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// functionPart is one statement-aligned piece of an oversized function.
+type functionPart struct {
+	Code               string
+	StartLine, EndLine int
+}
+
+// functionSplit is an oversized function cut into parts. Stub, the function with its body
+// elided, replaces the function's chunk so that links to the function stay valid.
+type functionSplit struct {
+	Stub  string
+	Parts []functionPart
+}
+
+// functionStub returns the signature of funcDecl with its body elided.
+func functionStub(funcDecl *ast.FuncDecl, fset *token.FileSet, src string) string {
+	start, lbrace := fset.Position(funcDecl.Pos()).Offset, fset.Position(funcDecl.Body.Lbrace).Offset+1
+	return src[start:lbrace] + partElided
+}
+
+// splitFunction breaks the body of funcDecl at top-level statement boundaries into parts
+// of at most maxTokens estimated tokens, each starting with the function signature so it
+// reads on its own. Comments between statements stay with the statement they precede; a
+// single statement larger than the budget becomes a part of its own. Each part after the
+// first also repeats the last statements of the part before it, up to overlap estimated
+// tokens. It returns nil when the function cannot be split.
+func splitFunction(funcDecl *ast.FuncDecl, fset *token.FileSet, src string, maxTokens, overlap int) []functionPart {
+	if funcDecl.Body == nil || len(funcDecl.Body.List) < 2 {
+		return nil
+	}
+	stmts := funcDecl.Body.List
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	start, lbrace, end := offset(funcDecl.Pos()), offset(funcDecl.Body.Lbrace)+1, offset(funcDecl.Body.End())
+	if start < 0 || end > len(src) || start > lbrace {
+		return nil
+	}
+	// The text of statement k runs from the end of the one before it, so it includes the
+	// comments above it; the last one also takes the closing brace.
+	segStart := func(k int) int {
+		if k == 0 {
+			return lbrace
+		}
+		return offset(stmts[k-1].End())
+	}
+	segEnd := func(k int) int {
+		if k == len(stmts)-1 {
+			return end
+		}
+		return offset(stmts[k].End())
+	}
+	for k := range stmts {
+		if segStart(k) > segEnd(k) {
+			return nil
+		}
+	}
+	header := src[start:lbrace]
+	// Room for the statements next to the signature, the elision markers and the
+	// statements repeated from the previous part.
+	budget := maxTokens - EstimateTokens(header) - EstimateTokens(partElided+partContinued) - overlap
+
+	// Group the statements greedily into parts.
+	var groups [][2]int
+	first := 0
+	for k := 1; k < len(stmts); k++ {
+		if EstimateTokens(src[segStart(first):segEnd(k)]) > budget {
+			groups = append(groups, [2]int{first, k - 1})
+			first = k
+		}
+	}
+	groups = append(groups, [2]int{first, len(stmts) - 1})
+	if len(groups) < 2 {
+		return nil
+	}
+
+	parts := make([]functionPart, len(groups))
+	for i, group := range groups {
+		from := group[0]
+		for i > 0 && from-1 > groups[i-1][0] && EstimateTokens(src[segStart(from-1):segStart(group[0])]) <= overlap {
+			from--
+		}
+		code := header
+		if i > 0 {
+			code += partContinued
+		}
+		code += src[segStart(from):segEnd(group[1])]
+		part := functionPart{StartLine: fset.Position(funcDecl.Pos()).Line, EndLine: fset.Position(stmts[group[1]].End()).Line}
+		if from > 0 {
+			// The part starts with the comments above its first statement.
+			prevEnd := fset.Position(stmts[from-1].End())
+			gap := src[prevEnd.Offset:offset(stmts[from].Pos())]
+			blank := gap[:len(gap)-len(strings.TrimLeft(gap, " \t\r\n"))]
+			part.StartLine = prevEnd.Line + strings.Count(blank, "\n")
+		}
+		if i < len(groups)-1 {
+			code += partElided
+		} else {
+			part.EndLine = fset.Position(funcDecl.Body.End()).Line
+		}
+		part.Code = code
+		parts[i] = part
+	}
+	return parts
+}
+
+// validateFunctionSplitting checks the split budget and the overlap between parts.
+func validateFunctionSplitting(maxTokens, overlap int) error {
+	if maxTokens < 0 || overlap < 0 {
+		return fmt.Errorf("split token budget and overlap must not be negative")
+	}
+	if overlap > 0 && overlap >= maxTokens {
+		return fmt.Errorf("split overlap (%d tokens) must be smaller than the split budget (%d tokens)", overlap, maxTokens)
+	}
+	return nil
+}
+
+// Markers for the statements left out of a part before and after it.
+const (
+	partContinued = "\n\t// ..."
+	partElided    = "\n\t// ...\n}"
+)
+
+// expandFunctionSplits replaces the document of each chunk at the indexes of splits with
+// its stub, adds "chunk_total", and follows it with one chunk per part. A part keeps the
+// metadata of its function, with its own lines, "parent_id" (the stub's ID), "chunk_index"
+// (from 1) and "chunk_total", so the function can be reassembled, and gets the ID
+// <parent ID>~part<index>; "~" keeps it apart from the "#n" suffix of symbol IDs.
+func expandFunctionSplits(chunks []ChromaDocument, splits map[int]functionSplit) []ChromaDocument {
+	expanded := make([]ChromaDocument, 0, len(chunks))
+	for i, chunk := range chunks {
+		split, ok := splits[i]
+		if !ok {
+			expanded = append(expanded, chunk)
+			continue
+		}
+		parts := split.Parts
+		chunk.Document = split.Stub
+		chunk.Metadata["chunk_total"] = len(parts)
+		expanded = append(expanded, chunk)
+		for index, part := range parts {
+			metadata := make(map[string]interface{}, len(chunk.Metadata)+5)
+			for k, v := range chunk.Metadata {
+				metadata[k] = v
+			}
+			metadata["parent_id"] = chunk.ID
+			metadata["chunk_index"] = index + 1
+			metadata["chunk_total"] = len(parts)
+			metadata["start_line"] = part.StartLine
+			metadata["end_line"] = part.EndLine
+			expanded = append(expanded, ChromaDocument{
+				ID:       fmt.Sprintf("%s~part%d", chunk.ID, index+1),
+				Document: part.Code,
+				Metadata: metadata,
+			})
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"go/ast"
+	"go/types"
+	"strconv"
+)
+
+// StructField describes one field of a struct type chunk. Embedded fields are named
+// after their type, as in Go.
+type StructField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Tag      string `json:"tag,omitempty"`
+	Embedded bool   `json:"embedded,omitempty"`
+}
+
+// structFields lists the fields of a struct type in declaration order.
+func structFields(structType *ast.StructType, info *types.Info) []StructField {
+	var fields []StructField
+	for _, field := range structType.Fields.List {
+		typeStr := getTypeString(field.Type, info)
+		tag := ""
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = unquoted
+			}
+		}
+		if len(field.Names) == 0 {
+			fields = append(fields, StructField{Name: embeddedFieldName(field.Type), Type: typeStr, Tag: tag, Embedded: true})
+			continue
+		}
+		for _, name := range field.Names {
+			fields = append(fields, StructField{Name: name.Name, Type: typeStr, Tag: tag})
+		}
+	}
+	return fields
+}
+
+// embeddedFieldName returns the name of an embedded field: its type name without
+// pointer, package qualifier and type arguments.
+func embeddedFieldName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return e.Sel.Name
+		case *ast.Ident:
+			return e.Name
+		default:
+			return types.ExprString(expr)
+		}
+	}
+}
+
+// setStructMetadata records the fields of a struct as "fields" and their names as
+// "field_names".
+func setStructMetadata(metadata map[string]interface{}, structType *ast.StructType, info *types.Info) {
+	fields := structFields(structType, info)
+	if len(fields) == 0 {
+		return
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	metadata["fields"] = fields
+	metadata["field_names"] = names
+}
@@ -0,0 +1,127 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// IDSchemeSymbol derives chunk IDs from the qualified symbol path
+	// ("example.com/app/server.Server.Start"), so they survive edits that move code.
+	IDSchemeSymbol = "symbol"
+	// IDSchemePositional uses "file:start-end-name" IDs, which change with every edit
+	// above the declaration.
+	IDSchemePositional = "positional"
+)
+
+// validateIDScheme checks the value of -id-scheme.
+func validateIDScheme(scheme string) error {
+	switch scheme {
+	case "", IDSchemeSymbol, IDSchemePositional:
+		return nil
+	}
+	return fmt.Errorf("unknown ID scheme %q (want %s or %s)", scheme, IDSchemeSymbol, IDSchemePositional)
+}
+
+// symbolChunkID returns the stable ID of a declaration: the package's import path (see
+// packageImportPath) and
+// the symbol ("Name" or "Type.Method"; see valueSymbol for value specs). Two
+// disambiguators keep IDs unique and deterministic:
+//   - "@file.go" is added for symbols that may be declared more than once per package
+//     (init functions and blank identifiers) and for declarations in files with build
+//     constraints, whose symbols may be declared again for another target;
+//   - "#n" is added to the n-th (n >= 2) declaration of the same symbol in one file.
+func symbolChunkID(importPath, symbol string, file *ast.File, filePath string, occurrence int) string {
+	id := importPath + "." + symbol
+	if repeatableSymbol(symbol) || fileHasBuildConstraints(file, filePath) {
+		id += "@" + filepath.Base(filePath)
+	}
+	if occurrence > 1 {
+		id += fmt.Sprintf("#%d", occurrence)
+	}
+	return id
+}
+
+// repeatableSymbol reports whether symbol may be declared several times in a package.
+func repeatableSymbol(symbol string) bool {
+	return symbol == "init" || symbol == "_"
+}
+
+// valueSymbol is the symbol part of a value spec's chunk ID: its first non-blank name,
+// which no other declaration of the package can share, or "_".
+func valueSymbol(valueSpec *ast.ValueSpec) string {
+	for _, name := range valueSpec.Names {
+		if name.Name != "_" {
+			return name.Name
+		}
+	}
+	return "_"
+}
+
+// funcSymbol is the symbol part of a function or method chunk ID.
+func funcSymbol(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+		return receiverBaseTypeName(funcDecl.Recv.List[0].Type) + "." + funcDecl.Name.Name
+	}
+	return funcDecl.Name.Name
+}
+
+// funcOccurrence counts the declarations of funcDecl's symbol in file up to and including
+// funcDecl.
+func funcOccurrence(file *ast.File, funcDecl *ast.FuncDecl) int {
+	symbol := funcSymbol(funcDecl)
+	n := 0
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && funcSymbol(fd) == symbol {
+			n++
+		}
+		if decl == funcDecl {
+			break
+		}
+	}
+	return n
+}
+
+// Known GOOS and GOARCH values, for recognizing "_linux.go"-style file name constraints.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+		"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+		"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "arm": true, "arm64": true, "loong64": true,
+		"mips": true, "mipsle": true, "mips64": true, "mips64le": true, "ppc64": true,
+		"ppc64le": true, "riscv64": true, "s390x": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// fileHasBuildConstraints reports whether a file is only built for some configurations,
+// through a //go:build (or // +build) line or a GOOS/GOARCH file name suffix.
+func fileHasBuildConstraints(file *ast.File, filePath string) bool {
+	if file != nil {
+		for _, group := range file.Comments {
+			if group.Pos() >= file.Package {
+				break
+			}
+			for _, comment := range group.List {
+				if strings.HasPrefix(comment.Text, "//go:build") || strings.HasPrefix(comment.Text, "// +build") {
+					return true
+				}
+			}
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(filePath), ".go")
+	name = strings.TrimSuffix(name, "_test")
+	parts := strings.Split(name, "_")
+	if n := len(parts); n >= 2 {
+		last := parts[n-1]
+		if knownOS[last] || knownArch[last] {
+			return true
+		}
+	}
+	return false
+}
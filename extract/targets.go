@@ -0,0 +1,125 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// validateTargets checks that every target is a "goos/goarch" pair and not repeated.
+func validateTargets(targets []string) error {
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		goos, goarch, ok := strings.Cut(target, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return fmt.Errorf("invalid target %q: want goos/goarch, e.g. linux/amd64", target)
+		}
+		if seen[target] {
+			return fmt.Errorf("target %s is given more than once", target)
+		}
+		seen[target] = true
+	}
+	return nil
+}
+
+// HostTarget is the target go list uses when none is selected.
+func HostTarget() string {
+	goos, goarch := os.Getenv("GOOS"), os.Getenv("GOARCH")
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	return goos + "/" + goarch
+}
+
+// targetEnv sets GOOS and GOARCH for target in env (os.Environ() when env is nil).
+func targetEnv(env []string, target string) []string {
+	goos, goarch, _ := strings.Cut(target, "/")
+	return withEnv(env, "GOOS="+goos, "GOARCH="+goarch)
+}
+
+// extractProjectTargets extracts projectPath once per target in opts.Targets (or for
+// the host target if none is set) and records in each chunk's "targets" metadata the
+// targets it was found under. A chunk seen under several targets, such as code without
+// build constraints, is emitted once. Like ExtractProject it returns partial results
+// when ctx is canceled.
+func extractProjectTargets(ctx context.Context, projectPath string, opts ExtractOptions) (*ExtractionResult, error) {
+	targets := opts.Targets
+	if len(targets) == 0 {
+		targets = []string{HostTarget()}
+	}
+
+	combined := &ExtractionResult{StartedAt: time.Now().UTC()}
+	chunkIndex := make(map[string]int)
+	entrypointIndex := make(map[string]int)
+	packageIndex := make(map[string]int)
+	for _, target := range targets {
+		targetOpts := opts
+		if len(opts.Targets) > 0 {
+			targetOpts.Target = target
+		}
+		result, err := ExtractProject(ctx, projectPath, targetOpts)
+		if result == nil {
+			return nil, fmt.Errorf("target %s: %w", target, err)
+		}
+		newChunks, newEntrypoints := len(combined.Chunks), len(combined.Entrypoints)
+		combined.Chunks = mergeTargetChunks(combined.Chunks, result.Chunks, chunkIndex, target)
+		combined.Entrypoints = mergeTargetChunks(combined.Entrypoints, result.Entrypoints, entrypointIndex, target)
+		// Packages already seen under an earlier target keep those statistics, plus the
+		// files and chunks only found under this one. Chunks are matched to packages by
+		// file, or by package_path for API digests.
+		earlier := make(map[string]int)
+		for _, stats := range result.Packages {
+			i, ok := packageIndex[stats.Path]
+			if !ok {
+				packageIndex[stats.Path] = len(combined.Packages)
+				combined.Packages = append(combined.Packages, stats)
+				continue
+			}
+			earlier[stats.Path] = i
+			for file, hash := range stats.FileHashes {
+				if combined.Packages[i].FileHashes == nil {
+					combined.Packages[i].FileHashes = make(map[string]string)
+				}
+				combined.Packages[i].FileHashes[file] = hash
+				earlier[file] = i
+			}
+		}
+		for _, chunks := range [][]ChromaDocument{combined.Chunks[newChunks:], combined.Entrypoints[newEntrypoints:]} {
+			for _, chunk := range chunks {
+				key, _ := chunk.Metadata["package_path"].(string)
+				if key == "" {
+					key, _ = chunk.Metadata["file_path"].(string)
+				}
+				if i, ok := earlier[key]; ok {
+					combined.Packages[i].Chunks++
+				}
+			}
+		}
+		if err != nil {
+			return combined, fmt.Errorf("target %s: %w", target, err)
+		}
+	}
+	return combined, nil
+}
+
+// mergeTargetChunks appends the chunks extracted for target to merged, adding target to
+// the "targets" list of chunks already present (index maps chunk IDs to positions).
+func mergeTargetChunks(merged, chunks []ChromaDocument, index map[string]int, target string) []ChromaDocument {
+	for _, chunk := range chunks {
+		if i, ok := index[chunk.ID]; ok {
+			targets, _ := merged[i].Metadata["targets"].([]string)
+			merged[i].Metadata["targets"] = append(targets, target)
+			continue
+		}
+		chunk.Metadata["targets"] = []string{target}
+		index[chunk.ID] = len(merged)
+		merged = append(merged, chunk)
+	}
+	return merged
+}
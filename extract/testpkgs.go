@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// With packages.Config.Tests set, packages.Load returns for a package p with tests the
+// plain package "p", its test variant "p [p.test]" (p's files plus its _test.go files),
+// the external test package "p_test [p.test]" and the generated test binary "p.test".
+// Chunks of non-test files come from the plain package only, so each declaration is
+// emitted once; test variants contribute just their _test.go files.
+
+// isTestVariant reports whether pkg was compiled for a test binary ("p [p.test]").
+func isTestVariant(pkg *packages.Package) bool {
+	return strings.Contains(pkg.ID, " [")
+}
+
+// isTestMainPackage reports whether pkg is the generated main package of a test binary.
+func isTestMainPackage(pkg *packages.Package) bool {
+	return pkg.Name == "main" && strings.HasSuffix(pkg.ID, ".test") && !isTestVariant(pkg)
+}
+
+func isTestFile(filePath string) bool {
+	return strings.HasSuffix(filePath, "_test.go")
+}
+
+// isTestFunc reports whether funcDecl is a "func TestXxx(t *testing.T)" run by go test.
+func isTestFunc(funcDecl *ast.FuncDecl) bool {
+	return isTestingFunc(funcDecl, "Test", "T")
+}
+
+// isBenchmarkFunc reports whether funcDecl is a "func BenchmarkXxx(b *testing.B)".
+func isBenchmarkFunc(funcDecl *ast.FuncDecl) bool {
+	return isTestingFunc(funcDecl, "Benchmark", "B")
+}
+
+// isFuzzFunc reports whether funcDecl is a "func FuzzXxx(f *testing.F)".
+func isFuzzFunc(funcDecl *ast.FuncDecl) bool {
+	return isTestingFunc(funcDecl, "Fuzz", "F")
+}
+
+// isTestingFunc reports whether funcDecl is named prefix+Xxx and takes a single
+// *testing.<param>.
+func isTestingFunc(funcDecl *ast.FuncDecl, prefix, param string) bool {
+	if funcDecl.Recv != nil || !hasTestPrefix(funcDecl.Name.Name, prefix) {
+		return false
+	}
+	params := funcDecl.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, isStar := params[0].Type.(*ast.StarExpr)
+	if !isStar {
+		return false
+	}
+	sel, isSel := star.X.(*ast.SelectorExpr)
+	return isSel && sel.Sel.Name == param
+}
+
+// testedSymbol derives the symbol a test, benchmark, fuzz test or example is named after
+// from the rest of its name: "Parse" for Parse, "Server_Start" for Server.Start, with an
+// optional suffix starting with a lower-case letter ("Parse_large").
+func testedSymbol(name string) (symbol, suffix string) {
+	parts := strings.Split(name, "_")
+	if last := parts[len(parts)-1]; len(parts) > 1 && last != "" && unicode.IsLower([]rune(last)[0]) {
+		suffix = last
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, "."), suffix
+}
+
+// testedSymbolPath qualifies a symbol from testedSymbol with the import path of the
+// package under test.
+func testedSymbolPath(importPath, symbol string) string {
+	path := strings.TrimSuffix(importPath, "_test")
+	if symbol == "" {
+		return path
+	}
+	return path + "." + symbol
+}
+
+// hasTestPrefix applies go test's naming rule: the prefix may be followed only by
+// nothing or a character that is not a lower-case letter.
+func hasTestPrefix(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
@@ -0,0 +1,11 @@
+package extract
+
+// DefaultTokenLimit matches the maximum sequence length of all-MiniLM-L6-v2, the
+// embedding model used by copy_chunks_to_chromadb.py. Anything longer is truncated.
+const DefaultTokenLimit = 256
+
+// EstimateTokens approximates the token count of a chunk using the common rule of thumb
+// of roughly four bytes per token. It is deliberately tokenizer-agnostic.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,56 @@
+package extract
+
+import (
+	"sort"
+	"strings"
+)
+
+// mergeMethodsIntoTypes replaces the chunk of every type that has methods with one chunk
+// holding the type declaration followed by all of its methods, gathered across files in
+// source order, and drops the method chunks. The merged chunk keeps the type chunk's ID and
+// metadata and adds the methods' names as "method_names" and their locations as
+// "method_locations". Methods whose type has no chunk stay as they are. Parts of split
+// methods are dropped with them; the indexes of the remaining splits are updated.
+func (b *retrievalBundles) mergeMethodsIntoTypes(chunks []ChromaDocument, splits map[int]functionSplit) ([]ChromaDocument, map[int]functionSplit) {
+	absorbed := make(map[int]bool)
+	typeNames := make([]string, 0, len(b.methodChunks))
+	for typeName := range b.methodChunks {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	for _, typeName := range typeNames {
+		typeIndex, hasType := b.typeChunks[typeName]
+		if !hasType {
+			continue
+		}
+		merged := chunks[typeIndex]
+		var names, locations []string
+		for _, idx := range b.methodChunks[typeName] {
+			method := chunks[idx]
+			merged.Document += "\n\n" + method.Document
+			name, _ := method.Metadata["entity_name"].(string)
+			names = append(names, name[strings.LastIndex(name, ".")+1:])
+			locations = append(locations, ChunkLocation(method.Metadata))
+			absorbed[idx] = true
+		}
+		merged.Metadata["method_names"] = names
+		merged.Metadata["method_locations"] = locations
+		chunks[typeIndex] = merged
+	}
+	if len(absorbed) == 0 {
+		return chunks, splits
+	}
+
+	kept := make([]ChromaDocument, 0, len(chunks)-len(absorbed))
+	keptSplits := make(map[int]functionSplit, len(splits))
+	for i, chunk := range chunks {
+		if absorbed[i] {
+			continue
+		}
+		if split, ok := splits[i]; ok {
+			keptSplits[len(kept)] = split
+		}
+		kept = append(kept, chunk)
+	}
+	return kept, keptSplits
+}
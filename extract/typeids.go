@@ -0,0 +1,174 @@
+package extract
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SignatureField describes one parameter or result of a function chunk. TypeIDs holds the
+// chunk IDs of the project-local named types the field's type refers to (including type
+// arguments and element types), so graph expansion needs no name resolution.
+type SignatureField struct {
+	Name    string   `json:"name,omitempty"`
+	Type    string   `json:"type"`
+	TypeIDs []string `json:"type_ids,omitempty"`
+}
+
+// positionalChunkID is the chunk ID format used for declarations: file, line span and name.
+func positionalChunkID(filePath string, startLine, endLine int, name string) string {
+	return fmt.Sprintf("%s:%d-%d-%s", filePath, startLine, endLine, name)
+}
+
+// buildTypeChunkIndex maps every type declared in the loaded, well-typed packages to the
+// ID of the chunk that will be emitted for its declaration under idScheme.
+func buildTypeChunkIndex(pkgs []*packages.Package, fset *token.FileSet, idScheme string) map[*types.TypeName]string {
+	index := make(map[*types.TypeName]string)
+	for _, pkg := range pkgs {
+		if !isWellTyped(pkg) {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			tokFile := fset.File(file.Pos())
+			if tokFile == nil {
+				continue
+			}
+			for _, decl := range file.Decls {
+				genDecl, isGenDecl := decl.(*ast.GenDecl)
+				if !isGenDecl || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					typeName, isTypeName := pkg.TypesInfo.Defs[typeSpec.Name].(*types.TypeName)
+					if !isTypeName {
+						continue
+					}
+					if idScheme == IDSchemePositional {
+						index[typeName] = positionalChunkID(tokFile.Name(),
+							fset.Position(spec.Pos()).Line, fset.Position(spec.End()).Line, typeSpec.Name.Name)
+					} else {
+						index[typeName] = symbolChunkID(packageImportPath(pkg), typeSpec.Name.Name, file, tokFile.Name(), 1)
+					}
+				}
+			}
+		}
+	}
+	return index
+}
+
+// referencedTypeIDs collects the chunk IDs of project-local named types reachable from t,
+// in first-seen order without duplicates.
+func referencedTypeIDs(t types.Type, index map[*types.TypeName]string) []string {
+	var ids []string
+	seen := make(map[types.Type]bool)
+
+	var visit func(types.Type)
+	visit = func(t types.Type) {
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+		switch x := t.(type) {
+		case *types.Named:
+			if id, ok := index[x.Origin().Obj()]; ok {
+				ids = appendUnique(ids, id)
+			}
+			if args := x.TypeArgs(); args != nil {
+				for i := 0; i < args.Len(); i++ {
+					visit(args.At(i))
+				}
+			}
+		case *types.Alias:
+			if id, ok := index[x.Obj()]; ok {
+				ids = appendUnique(ids, id)
+			}
+			visit(types.Unalias(x))
+		case *types.Pointer:
+			visit(x.Elem())
+		case *types.Slice:
+			visit(x.Elem())
+		case *types.Array:
+			visit(x.Elem())
+		case *types.Map:
+			visit(x.Key())
+			visit(x.Elem())
+		case *types.Chan:
+			visit(x.Elem())
+		case *types.Signature:
+			for i := 0; i < x.Params().Len(); i++ {
+				visit(x.Params().At(i).Type())
+			}
+			for i := 0; i < x.Results().Len(); i++ {
+				visit(x.Results().At(i).Type())
+			}
+		}
+	}
+	visit(t)
+	return ids
+}
+
+// appendUnique appends value unless it is already present.
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// signatureFields describes a parameter or result tuple.
+func signatureFields(tuple *types.Tuple, index map[*types.TypeName]string) ([]SignatureField, []string) {
+	var fields []SignatureField
+	var allIDs []string
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		field := SignatureField{
+			Name:    v.Name(),
+			Type:    v.Type().String(),
+			TypeIDs: referencedTypeIDs(v.Type(), index),
+		}
+		for _, id := range field.TypeIDs {
+			allIDs = appendUnique(allIDs, id)
+		}
+		fields = append(fields, field)
+	}
+	return fields, allIDs
+}
+
+// addSignatureTypeIDs records structured params/results for a function or method, the
+// chunk ID of its receiver type, and the union of all referenced type chunk IDs.
+func addSignatureTypeIDs(metadata map[string]interface{}, fn *types.Func, index map[*types.TypeName]string) {
+	sig, isSig := fn.Type().(*types.Signature)
+	if !isSig {
+		return
+	}
+
+	params, referenced := signatureFields(sig.Params(), index)
+	results, resultIDs := signatureFields(sig.Results(), index)
+	for _, id := range resultIDs {
+		referenced = appendUnique(referenced, id)
+	}
+	if len(params) > 0 {
+		metadata["params"] = params
+	}
+	if len(results) > 0 {
+		metadata["results"] = results
+	}
+
+	if sig.Recv() != nil {
+		if named := namedTypeOf(sig.Recv().Type()); named != nil {
+			if id, ok := index[named.Origin().Obj()]; ok {
+				metadata["receiver_type_id"] = id
+				referenced = appendUnique(referenced, id)
+			}
+		}
+	}
+	if len(referenced) > 0 {
+		metadata["referenced_type_ids"] = referenced
+	}
+}
@@ -0,0 +1,62 @@
+package extract
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	// VendorSkip drops packages read from a vendor/ directory.
+	VendorSkip = "skip"
+	// VendorInclude indexes vendored packages and marks their chunks vendored=true.
+	VendorInclude = "include"
+	// VendorFirstParty keeps only packages of the main module (or workspace modules),
+	// which also drops dependencies matched by explicit package patterns.
+	VendorFirstParty = "first-party"
+)
+
+// validateVendorPolicy checks the value of -vendor.
+func validateVendorPolicy(policy string) error {
+	switch policy {
+	case "", VendorSkip, VendorInclude, VendorFirstParty:
+		return nil
+	}
+	return fmt.Errorf("unknown vendor policy %q (want %s, %s or %s)", policy, VendorSkip, VendorInclude, VendorFirstParty)
+}
+
+// isVendoredPackage reports whether pkg's sources live in a vendor/ directory.
+func isVendoredPackage(pkg *packages.Package) bool {
+	files := pkg.CompiledGoFiles
+	if len(files) == 0 {
+		files = pkg.GoFiles
+	}
+	for _, file := range files {
+		if strings.Contains(filepath.ToSlash(file), "/vendor/") {
+			return true
+		}
+	}
+	return strings.HasPrefix(pkg.PkgPath, "vendor/") || strings.Contains(pkg.PkgPath, "/vendor/")
+}
+
+// isFirstPartyPackage reports whether pkg belongs to a main module. Outside module mode
+// there is no module information and every package that is not vendored counts.
+func isFirstPartyPackage(pkg *packages.Package) bool {
+	if isVendoredPackage(pkg) {
+		return false
+	}
+	return pkg.Module == nil || pkg.Module.Main
+}
+
+// skipByVendorPolicy reports whether the vendor policy leaves pkg out.
+func skipByVendorPolicy(pkg *packages.Package, policy string) bool {
+	switch policy {
+	case VendorInclude:
+		return false
+	case VendorFirstParty:
+		return !isFirstPartyPackage(pkg)
+	}
+	return isVendoredPackage(pkg)
+}
@@ -0,0 +1,140 @@
+package extract
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Workspace is a go.work file and the modules it uses.
+type Workspace struct {
+	File    string
+	Modules []WorkspaceModule
+}
+
+// WorkspaceModule is one "use" directive of a go.work file.
+type WorkspaceModule struct {
+	Dir  string
+	Path string
+}
+
+// loadWorkspace parses dir/go.work and the go.mod of every module it uses. It returns
+// nil without an error when dir has no go.work.
+func loadWorkspace(dir string) (*Workspace, error) {
+	file := filepath.Join(dir, "go.work")
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	work, err := modfile.ParseWork(file, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	ws := &Workspace{File: file}
+	for _, use := range work.Use {
+		moduleDir := use.Path
+		if !filepath.IsAbs(moduleDir) {
+			moduleDir = filepath.Join(dir, moduleDir)
+		}
+		modulePath, err := readModulePath(moduleDir)
+		if err != nil {
+			return nil, fmt.Errorf("workspace module %s: %w", use.Path, err)
+		}
+		ws.Modules = append(ws.Modules, WorkspaceModule{Dir: filepath.Clean(moduleDir), Path: modulePath})
+	}
+	if len(ws.Modules) == 0 {
+		return nil, fmt.Errorf("%s uses no modules", file)
+	}
+	return ws, nil
+}
+
+// readModulePath returns the module path declared in dir/go.mod.
+func readModulePath(dir string) (string, error) {
+	file := filepath.Join(dir, "go.mod")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	mod, err := modfile.ParseLax(file, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if mod.Module == nil {
+		return "", fmt.Errorf("%s has no module directive", file)
+	}
+	return mod.Module.Mod.Path, nil
+}
+
+// patterns matches every package of every workspace module. "./..." cannot be used at a
+// workspace root: it only covers modules below the root and fails if there are none.
+func (ws *Workspace) patterns() []string {
+	patterns := make([]string, 0, len(ws.Modules))
+	for _, module := range ws.Modules {
+		patterns = append(patterns, module.Path+"/...")
+	}
+	return patterns
+}
+
+// uses reports whether dir is one of the workspace modules.
+func (ws *Workspace) uses(dir string) bool {
+	for _, module := range ws.Modules {
+		if module.Dir == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingWorkspace finds the go.work the go command would pick up for a module in dir:
+// the nearest one in dir or its parents.
+func enclosingWorkspace(dir string) (*Workspace, error) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return loadWorkspace(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// workspaceEnv decides how projectPath is loaded with respect to go.work files. A module
+// inside a workspace that does not use it cannot be loaded in workspace mode, so such a
+// module gets GOWORK=off; a workspace root needs no change. It returns the environment
+// to load with, unchanged when env needs no override.
+func workspaceEnv(env []string, projectPath string) []string {
+	if os.Getenv("GOWORK") != "" {
+		return env
+	}
+	if _, err := os.Stat(filepath.Join(projectPath, "go.mod")); err != nil {
+		return env
+	}
+	ws, err := enclosingWorkspace(projectPath)
+	if err != nil {
+		log.Printf("Warning: ignoring enclosing workspace of %s: %v", projectPath, err)
+		return withEnv(env, "GOWORK=off")
+	}
+	if ws != nil && !ws.uses(projectPath) {
+		log.Printf("%s is not used by workspace %s; loading it on its own.", projectPath, ws.File)
+		return withEnv(env, "GOWORK=off")
+	}
+	return env
+}
+
+// withEnv appends variables to env, starting from the process environment when env is nil.
+func withEnv(env []string, vars ...string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(env, vars...)
+}
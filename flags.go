@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// ExtractionFlags are the flags of extract and serve that configure chunk extraction.
+type ExtractionFlags struct {
+	Options      extract.ExtractOptions
+	Packages     string
+	BuildTags    string
+	Targets      string
+	Include      string
+	Exclude      string
+	IDTemplate   string
+	PriorityFile string
+}
+
+// addExtractionFlags registers the extraction flags on fs.
+func addExtractionFlags(fs *flag.FlagSet, f *ExtractionFlags) {
+	opts := &f.Options
+	fs.BoolVar(&opts.NoDeps, "no-deps", false, "Do not load the dependency graph (faster; imports are type-checked from export data)")
+	fs.StringVar(&f.Packages, "packages", "", "Comma-separated list of package patterns to load instead of ./...")
+	fs.StringVar(&f.Targets, "target", "", "Comma-separated goos/goarch targets to extract for (e.g. linux/amd64,windows/amd64); default is the host")
+	fs.StringVar(&f.BuildTags, "tags", "", "Comma-separated build tags to load packages with (e.g. integration,linux)")
+	fs.BoolVar(&opts.PackageSummary, "package-summary", true, "Emit a per-package summary chunk of the package doc, imports and exported symbol signatures")
+	fs.BoolVar(&opts.APIDigest, "api-digest", false, "Emit a per-package digest chunk of exported constants and variables")
+	fs.BoolVar(&opts.RetrievalBundles, "retrieval-bundles", false, "Link each method chunk to its type chunk and sibling method chunks (bundle_type_id, bundle_method_ids)")
+	fs.BoolVar(&opts.QualityGuard, "quality-guard", false, "Flag chunks dominated by generated tables, encoded blobs or binary literals as embedding_skipped")
+	fs.IntVar(&opts.QualityThresholds.MinBlobLength, "min-blob-length", extract.DefaultQualityThresholds.MinBlobLength, "Quality guard: minimum string literal length checked for encoded data")
+	fs.Float64Var(&opts.QualityThresholds.MaxLiteralEntropy, "max-literal-entropy", extract.DefaultQualityThresholds.MaxLiteralEntropy, "Quality guard: entropy (bits/byte) above which a long literal counts as encoded data")
+	fs.Float64Var(&opts.QualityThresholds.MaxBlobRatio, "max-blob-ratio", extract.DefaultQualityThresholds.MaxBlobRatio, "Quality guard: maximum share of a chunk's bytes that may be blob literals")
+	fs.Float64Var(&opts.QualityThresholds.MaxLiteralTokenRatio, "max-literal-token-ratio", extract.DefaultQualityThresholds.MaxLiteralTokenRatio, "Quality guard: maximum share of literal tokens in a large chunk before it counts as a generated table")
+	opts.QualityThresholds.MinTableTokens = extract.DefaultQualityThresholds.MinTableTokens
+	fs.StringVar(&opts.PackageOrder, "package-order", extract.PackageOrderLoad, "Order to process packages in: load, recency (latest git change first) or priority (see -priority-file)")
+	fs.StringVar(&f.PriorityFile, "priority-file", "", "File listing package patterns (one per line, e.g. example.com/app/core/...) to process first with -package-order=priority")
+	fs.DurationVar(&opts.TimeBudget, "time-budget", 0, "Stop processing further packages after this long (0 means no limit)")
+	fs.StringVar(&opts.BinaryPath, "binary", "", "Correlate functions with the symbols of this built Go binary (in_binary, binary_size)")
+	fs.BoolVar(&opts.ContextHeader, "context-header", false, "Add the file's package clause and imports to every chunk as context_header metadata")
+	fs.BoolVar(&opts.SkipMain, "skip-main", false, "Exclude \"package main\" entrypoints from the output")
+	fs.BoolVar(&opts.SkipCmd, "skip-cmd", false, "Exclude packages under cmd/ directories from the output")
+	fs.BoolVar(&opts.Tests, "tests", false, "Also extract _test.go files; test functions get entity_type \"test\"")
+	fs.StringVar(&opts.Generated, "generated", extract.GeneratedInclude, "Handling of generated files (\"// Code generated ... DO NOT EDIT.\"): include (marked generated=true) or skip")
+	fs.BoolVar(&opts.NestedModules, "nested-modules", false, "Also extract the modules nested below the project directory, as a go.work workspace would (ignored with -packages)")
+	fs.StringVar(&opts.Vendor, "vendor", extract.VendorSkip, "Handling of vendored packages: skip, include (marked vendored=true) or first-party (main module packages only)")
+	fs.IntVar(&opts.SplitTokens, "split-tokens", 0, "Split functions longer than this many estimated tokens at statement boundaries into parts with parent_id, chunk_index and chunk_total metadata; the function chunk keeps only its signature (0 disables; see -token-limit)")
+	fs.IntVar(&opts.ClosureTokens, "closure-tokens", 0, "Also emit closures of at least this many estimated tokens as child chunks (entity_type closure, named like ServeHTTP$1; 0 disables)")
+	fs.BoolVar(&opts.EmbedAssets, "embed-assets", false, "Also emit text files embedded with //go:embed (templates, SQL, configs) as chunks linked to their variable")
+	fs.BoolVar(&opts.InterfaceMethodChunks, "interface-method-chunks", false, "Also emit a chunk per interface method (entity_type interface_method, parent_id of the interface chunk)")
+	fs.IntVar(&opts.GroupTokens, "group-tokens", 0, "Combine consecutive var/const specs of a parenthesized declaration that are each under this many estimated tokens into one chunk listing their entity_names (0 disables)")
+	fs.IntVar(&opts.SplitOverlap, "split-overlap", 0, "With -split-tokens, repeat up to this many estimated tokens of statements from the end of each part at the start of the next")
+	fs.BoolVar(&opts.IncludeDocs, "include-docs", true, "Prepend each declaration's doc comment to its chunk text (the godoc is usually the text that matches a search best)")
+	fs.StringVar(&opts.QualifierRewrite, "qualifiers", extract.QualifierRewriteFull, "Package qualifiers in chunk text: full (rewrite to import paths) or none (as written)")
+	fs.StringVar(&opts.Granularity, "granularity", extract.GranularityDecl, "Chunks to emit: decl (one per declaration), file (one per source file), both, or type (one per declaration, with each type's methods merged into its chunk)")
+	fs.StringVar(&opts.IDScheme, "id-scheme", extract.IDSchemeSymbol, "Chunk IDs: symbol (qualified symbol path, stable across edits) or positional (file:lines-name)")
+	fs.StringVar(&f.IDTemplate, "id-template", "", "Go template for chunk IDs, e.g. {{.Package}}:{{.Entity}} (fields: Package, PackageName, Entity, EntityType, QualifiedName, File, FilePath, StartLine, EndLine, DefaultID, Meta)")
+	fs.StringVar(&f.Include, "include", "", "Comma-separated globs of files to extract, relative to the project root (e.g. internal/**); default is all files")
+	fs.StringVar(&f.Exclude, "exclude", "", "Comma-separated globs of files to leave out, relative to the project root (e.g. **/generated/**,**/mocks/**)")
+	fs.BoolVar(&opts.Nice, "nice", false, "Run as a low-priority background job: limit CPU parallelism and I/O rate")
+	fs.IntVar(&opts.NiceIORate, "nice-io-rate", extract.DefaultNiceIORate, "Maximum source read rate in bytes/second when -nice is set")
+	fs.IntVar(&opts.IndentWidth, "indent-width", 0, "Convert leading tabs in chunk text to this many spaces (0 keeps tabs)")
+	fs.BoolVar(&opts.Dedent, "dedent", false, "Remove indentation inherited from the enclosing block from chunk text")
+	fs.StringVar(&opts.InvalidUTF8, "invalid-utf8", extract.InvalidUTF8Transcode, "Handling of non-UTF-8 source files: transcode (Latin-1 fallback), replace, or skip")
+	fs.BoolVar(&opts.SearchText, "search-text", false, "Add a lowercased, identifier-split search_text metadata field to every chunk")
+}
+
+// options returns the validated extraction options, with patterns added to -packages.
+func (f *ExtractionFlags) options(patterns []string) (extract.ExtractOptions, error) {
+	opts := f.Options
+	opts.Packages = append(splitList(f.Packages), patterns...)
+	opts.BuildTags = splitList(f.BuildTags)
+	opts.Targets = splitList(f.Targets)
+	opts.Paths = extract.PathFilter{Include: splitList(f.Include), Exclude: splitList(f.Exclude)}
+	if err := opts.Validate(); err != nil {
+		return opts, err
+	}
+	if f.IDTemplate != "" {
+		idFunc, err := extract.TemplateIDFunc(f.IDTemplate)
+		if err != nil {
+			return opts, err
+		}
+		opts.IDFunc = idFunc
+	}
+	if f.PriorityFile != "" {
+		priorities, err := extract.LoadPriorityList(f.PriorityFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.PackagePriorities = priorities
+	} else if opts.PackageOrder == extract.PackageOrderPriority {
+		return opts, fmt.Errorf("-package-order=priority needs -priority-file")
+	}
+	return opts, nil
+}
+
+// OutputFlags are the flags of extract and serve that configure how chunk files are written.
+type OutputFlags struct {
+	Options        OutputOptions
+	CSVColumns     string
+	EncryptKeyFile string
+	Embedding      EmbeddingOptions
+	EmbedCacheDir  string
+}
+
+// addOutputFlags registers the output file and embedding flags on fs.
+func addOutputFlags(fs *flag.FlagSet, f *OutputFlags) {
+	out := &f.Options
+	fs.StringVar(&out.Format, "format", "", "Output format: json (one array), jsonl (one chunk per line, written as packages finish), parquet (id, document and metadata columns), csv (see -csv-columns), pb (protobuf ChunkSet, see chunk.proto) or bulk (Elasticsearch/OpenSearch _bulk NDJSON for POST /<index>/_bulk); default by -out extension (.jsonl/.ndjson/.parquet/.csv/.pb)")
+	fs.StringVar(&out.Compression, "compress", "", "Compress the chunk output: none, gzip or zstd; default by -out extension (.gz/.zst)")
+	fs.StringVar(&f.CSVColumns, "csv-columns", strings.Join(defaultCSVColumns, ","), "Comma-separated metadata fields written as columns of CSV output, after id and document")
+	fs.IntVar(&out.MaxChunksPerFile, "max-chunks-per-file", 0, "Split the chunk output into numbered files (e.g. code_chunks_0001.json) of at most this many chunks (0 means no limit)")
+	fs.Int64Var(&out.MaxBytesPerFile, "max-bytes-per-file", 0, "Split the chunk output into numbered files of about this many bytes of uncompressed JSON (0 means no limit)")
+	fs.BoolVar(&out.Dedupe, "dedupe", false, "Emit chunks with byte-identical text once, recording the IDs and source locations of the copies (duplicate_ids, source_locations); needs the whole chunk set, so output is not streamed")
+	fs.BoolVar(&out.Manifest, "manifest", true, "Write a manifest with per-package chunk counts, file hashes, output checksums and timestamps next to the output")
+	fs.IntVar(&out.TokenLimit, "token-limit", extract.DefaultTokenLimit, "Embedding model context limit (in tokens) used to flag oversized chunks")
+	fs.BoolVar(&out.Incremental, "incremental", false, "Only emit chunks that changed since the previous run recorded in -state")
+	fs.StringVar(&f.EncryptKeyFile, "encrypt-key-file", "", "Encrypt output artifacts with AES-256-GCM using the key in this file (or set $"+encryptionKeyEnv+")")
+	embedding := &f.Embedding
+	fs.StringVar(&embedding.Model, "embed-model", "", "Compute chunk embeddings with this model (e.g. text-embedding-3-small) and include them in the output or Chroma upload")
+	fs.StringVar(&embedding.Provider, "embed-provider", embeddingProviderOpenAI, "Embedding provider: "+strings.Join(embeddingProviderNames(), ", "))
+	fs.StringVar(&embedding.URL, "embed-url", "", "Base URL of the embedding API (default: the provider's, e.g. "+defaultOpenAIURL+")")
+	fs.StringVar(&embedding.APIKey, "embed-api-key", "", "API key for the embedding API (or set $"+embeddingAPIKeyEnv+" for openai)")
+	fs.IntVar(&embedding.Dimensions, "embed-dimensions", 0, "Embedding size to request from models that support shortening (0 keeps the model's default)")
+	fs.IntVar(&embedding.BatchSize, "embed-batch-size", defaultEmbeddingBatchSize, "Number of chunks per embedding request")
+	fs.IntVar(&embedding.Concurrency, "embed-concurrency", defaultEmbeddingConcurrency, "Maximum number of embedding requests in flight")
+	fs.Float64Var(&embedding.RequestsPerSecond, "embed-qps", 0, "Maximum embedding requests per second (0 means no limit)")
+	fs.IntVar(&embedding.TokensPerMinute, "embed-tpm", 0, "Maximum estimated input tokens sent for embedding per minute (0 means no limit)")
+	fs.IntVar(&embedding.MaxRetries, "embed-max-retries", defaultEmbeddingRetries, "Retries of an embedding request failing with a rate limit, server or network error")
+	fs.StringVar(&f.EmbedCacheDir, "embed-cache", defaultEmbeddingCacheDir, "Directory caching embeddings by chunk text and model, so only changed chunks are embedded (empty disables the cache)")
+}
+
+// options returns the validated output options with the encryption key loaded and the
+// embedder set up.
+func (f *OutputFlags) options() (OutputOptions, error) {
+	out := f.Options
+	out.CSVColumns = splitList(f.CSVColumns)
+	if err := validateOutputFormat(out.Format); err != nil {
+		return out, err
+	}
+	if err := validateCompression(out.Compression); err != nil {
+		return out, err
+	}
+	if err := validateShardLimits(out); err != nil {
+		return out, err
+	}
+	key, err := loadEncryptionKey(f.EncryptKeyFile)
+	if err != nil {
+		return out, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	out.EncryptionKey = key
+	embedding := f.Embedding
+	if embedding.Model == "" {
+		return out, nil
+	}
+	if embedding.BatchSize <= 0 || embedding.Concurrency <= 0 {
+		return out, fmt.Errorf("-embed-batch-size and -embed-concurrency must be positive")
+	}
+	if embedding.RequestsPerSecond < 0 || embedding.TokensPerMinute < 0 || embedding.MaxRetries < 0 {
+		return out, fmt.Errorf("-embed-qps, -embed-tpm and -embed-max-retries must not be negative")
+	}
+	embedder, err := newEmbeddingProvider(embedding)
+	if err != nil {
+		return out, err
+	}
+	embedder = newLimitedEmbedder(embedder, embedding)
+	if f.EmbedCacheDir != "" {
+		embedder = newEmbeddingCache(embedder, f.EmbedCacheDir, embedding, key)
+	}
+	out.Embedder = embedder
+	out.EmbeddingBatchSize, out.EmbeddingConcurrency = embedding.BatchSize, embedding.Concurrency
+	return out, nil
+}
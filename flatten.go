@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Handling of list metadata values.
+const (
+	// listsJoin joins lists of scalars with the delimiter; other lists are JSON-encoded.
+	listsJoin = "join"
+	// listsJSON JSON-encodes every list.
+	listsJSON = "json"
+)
+
+// Handling of map metadata values.
+const (
+	// mapsJSON JSON-encodes maps.
+	mapsJSON = "json"
+	// mapsFlatten replaces a map with one key per entry, e.g. "binary.size".
+	mapsFlatten = "flatten"
+)
+
+// Handling of null metadata values.
+const (
+	// nullsDrop leaves nil values out.
+	nullsDrop = "drop"
+	// nullsEmpty turns nil values into empty strings.
+	nullsEmpty = "empty"
+)
+
+// MetadataFlattening are the rules for turning chunk metadata into the flat string,
+// number and boolean values vector stores such as Chroma accept.
+type MetadataFlattening struct {
+	Lists     string
+	Delimiter string
+	Maps      string
+	Nulls     string
+}
+
+var defaultMetadataFlattening = MetadataFlattening{Lists: listsJoin, Delimiter: ", ", Maps: mapsJSON, Nulls: nullsDrop}
+
+// addMetadataFlatteningFlags defines the flattening flags on fs.
+func addMetadataFlatteningFlags(fs *flag.FlagSet, rules *MetadataFlattening) {
+	fs.StringVar(&rules.Lists, "metadata-lists", defaultMetadataFlattening.Lists, "Uploaded list metadata: join (scalars joined with -metadata-list-delimiter) or json")
+	fs.StringVar(&rules.Delimiter, "metadata-list-delimiter", defaultMetadataFlattening.Delimiter, "Delimiter for joined list metadata")
+	fs.StringVar(&rules.Maps, "metadata-maps", defaultMetadataFlattening.Maps, "Uploaded map metadata: json or flatten (one key per entry, e.g. binary.size)")
+	fs.StringVar(&rules.Nulls, "metadata-nulls", defaultMetadataFlattening.Nulls, "Uploaded null metadata: drop or empty (empty string)")
+}
+
+// validateMetadataFlattening checks the flattening rules.
+func validateMetadataFlattening(rules MetadataFlattening) error {
+	if rules.Lists != listsJoin && rules.Lists != listsJSON {
+		return fmt.Errorf("unknown list handling %q (want %s or %s)", rules.Lists, listsJoin, listsJSON)
+	}
+	if rules.Maps != mapsJSON && rules.Maps != mapsFlatten {
+		return fmt.Errorf("unknown map handling %q (want %s or %s)", rules.Maps, mapsJSON, mapsFlatten)
+	}
+	if rules.Nulls != nullsDrop && rules.Nulls != nullsEmpty {
+		return fmt.Errorf("unknown null handling %q (want %s or %s)", rules.Nulls, nullsDrop, nullsEmpty)
+	}
+	return nil
+}
+
+// flattenMetadata converts metadata to string, int64, float64 and bool values by rules.
+func flattenMetadata(metadata map[string]interface{}, rules MetadataFlattening) map[string]interface{} {
+	flat := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		rules.flatten(flat, key, value)
+	}
+	return flat
+}
+
+func (rules MetadataFlattening) flatten(flat map[string]interface{}, key string, value interface{}) {
+	if value == nil {
+		if rules.Nulls == nullsEmpty {
+			flat[key] = ""
+		}
+		return
+	}
+	if scalar, ok := flatScalar(value); ok {
+		flat[key] = scalar
+		return
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			rules.flatten(flat, key, nil)
+			return
+		}
+		rules.flatten(flat, key, v.Elem().Interface())
+		return
+	case reflect.Slice, reflect.Array:
+		if rules.Lists == listsJoin {
+			if joined, ok := joinScalars(v, rules.Delimiter); ok {
+				flat[key] = joined
+				return
+			}
+		}
+	case reflect.Map:
+		if rules.Maps == mapsFlatten && v.Type().Key().Kind() == reflect.String {
+			iter := v.MapRange()
+			for iter.Next() {
+				rules.flatten(flat, key+"."+iter.Key().String(), iter.Value().Interface())
+			}
+			return
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		flat[key] = fmt.Sprint(value)
+		return
+	}
+	flat[key] = string(data)
+}
+
+// flatScalar converts strings, booleans and numbers of any Go type to string, bool,
+// int64 or float64.
+func flatScalar(value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n <= 1<<63-1 {
+			return int64(n), true
+		}
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return nil, false
+}
+
+// joinScalars joins a list of strings, numbers and booleans with delimiter.
+func joinScalars(list reflect.Value, delimiter string) (string, bool) {
+	parts := make([]string, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i).Interface()
+		scalar, ok := flatScalar(item)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, fmt.Sprint(scalar))
+	}
+	return strings.Join(parts, delimiter), true
+}
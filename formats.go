@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+const (
+	// formatJSON writes all chunks as one indented JSON array.
+	formatJSON = "json"
+	// formatJSONL writes one JSON chunk per line as chunks are produced.
+	formatJSONL = "jsonl"
+	// formatParquet writes Parquet rows with a fixed column schema (see parquetChunkRow).
+	formatParquet = "parquet"
+	// formatCSV writes id, document and selected metadata columns for auditing.
+	formatCSV = "csv"
+)
+
+// validateOutputFormat checks the value of -format; "" selects the format by the output
+// file's extension.
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", formatJSON, formatJSONL, formatParquet, formatCSV, formatPB, formatBulk:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want %s, %s, %s, %s, %s or %s)", format, formatJSON, formatJSONL, formatParquet, formatCSV, formatPB, formatBulk)
+}
+
+// outputFormat resolves the format of path: format if set, otherwise jsonl for stdout
+// and for .jsonl and .ndjson files (also compressed, e.g. .jsonl.gz), parquet for
+// .parquet files, csv for .csv files, pb for .pb files and json for everything else.
+func outputFormat(format, path string) string {
+	if format != "" {
+		return format
+	}
+	if path == stdoutOutput {
+		return formatJSONL
+	}
+	switch strings.ToLower(filepath.Ext(trimCompressionExt(path))) {
+	case ".jsonl", ".ndjson":
+		return formatJSONL
+	case ".parquet":
+		return formatParquet
+	case ".csv":
+		return formatCSV
+	case ".pb":
+		return formatPB
+	}
+	return formatJSON
+}
+
+// newFileSink returns the sink writing chunks to path as set in out, compressed with
+// out.Compression (format and compression are chosen by path's extensions when empty)
+// and split into shards when out sets a shard limit.
+func newFileSink(path string, out OutputOptions) extract.Sink {
+	if out.MaxChunksPerFile > 0 || out.MaxBytesPerFile > 0 {
+		sharded := out
+		sharded.MaxChunksPerFile, sharded.MaxBytesPerFile = 0, 0
+		return &shardedSink{path: path, out: sharded, maxChunks: out.MaxChunksPerFile, maxBytes: out.MaxBytesPerFile}
+	}
+	format, key := out.Format, out.EncryptionKey
+	compression := outputCompression(out.Compression, path)
+	switch outputFormat(format, path) {
+	case formatCSV:
+		sink := newCSVFileSink(path, key, out.CSVColumns)
+		sink.compression = compression
+		return sink
+	case formatPB:
+		sink := &lineFileSink{path: path, key: key, header: protoSchemaHeader(), encode: encodeProtoChunk}
+		sink.compression = compression
+		return sink
+	case formatBulk:
+		sink := &lineFileSink{path: path, key: key, encode: encodeBulkChunk}
+		sink.compression = compression
+		return sink
+	case formatJSONL:
+		sink := newJSONLFileSink(path, key)
+		sink.compression = compression
+		return sink
+	case formatParquet:
+		sink := newParquetFileSink(path, key)
+		sink.compression = compression
+		return sink
+	}
+	sink := newJSONFileSink(path, key)
+	sink.compression = compression
+	return sink
+}
+
+// lineFileSink writes one line per chunk (a JSON object for JSONL, a record for CSV, a
+// ChunkSet.chunks field for protobuf, an action and a document line for bulk), after an
+// optional header (the CSV column names, the protobuf schema version). Lines go to a
+// temporary file next to path as they are written, and the first Flush (or Close)
+// renames it into place; later writes append to the renamed file. Encrypted output is whole-file, so with a key the
+// lines are collected and written by Flush instead.
+type lineFileSink struct {
+	path        string
+	key         []byte
+	compression string
+
+	header  []byte
+	encode  func(extract.ChromaDocument) ([]byte, error)
+	started bool // The header was written.
+
+	file    *os.File
+	w       compressWriter
+	renamed bool
+	buf     bytes.Buffer // Collected lines when encrypting.
+}
+
+// newJSONLFileSink returns a sink writing path, encrypted when key is set.
+func newJSONLFileSink(path string, key []byte) *lineFileSink {
+	return &lineFileSink{path: path, key: key, encode: func(chunk extract.ChromaDocument) ([]byte, error) {
+		line, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal chunk %s: %w", chunk.ID, err)
+		}
+		return append(line, '\n'), nil
+	}}
+}
+
+func (s *lineFileSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	if s.key == nil && s.w == nil && s.path == stdoutOutput {
+		w, err := newCompressWriter(os.Stdout, s.compression)
+		if err != nil {
+			return err
+		}
+		s.w = w
+	}
+	if s.key == nil && s.w == nil {
+		file, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp-*")
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		w, err := newCompressWriter(file, s.compression)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return err
+		}
+		s.file, s.w = file, w
+	}
+	if !s.started {
+		s.started = true
+		if err := s.writeLine(s.header); err != nil {
+			return err
+		}
+	}
+	for _, chunk := range chunks {
+		line, err := s.encode(chunk)
+		if err != nil {
+			return err
+		}
+		if err := s.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *lineFileSink) writeLine(line []byte) error {
+	if s.key != nil {
+		s.buf.Write(line)
+		return nil
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	return nil
+}
+
+func (s *lineFileSink) Flush(ctx context.Context) error {
+	if !s.started {
+		// Nothing was written: still replace the output with an empty file.
+		if err := s.Write(ctx, nil); err != nil {
+			return err
+		}
+	}
+	if s.key != nil {
+		data, err := compressData(s.buf.Bytes(), s.compression)
+		if err != nil {
+			return err
+		}
+		if err := writeArtifact(s.path, data, s.key); err != nil {
+			return fmt.Errorf("failed to write chunks: %w", err)
+		}
+		return nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	if s.renamed || s.file == nil {
+		return nil
+	}
+	if err := os.Chmod(s.file.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(s.file.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	s.renamed = true
+	return nil
+}
+
+// Abort closes the sink without flushing; the temporary file is removed unless it
+// already replaced the output.
+func (s *lineFileSink) Abort() error {
+	s.buf.Reset()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if !s.renamed {
+		os.Remove(s.file.Name())
+	}
+	return err
+}
+
+func (s *lineFileSink) Close() error {
+	err := s.Flush(context.Background())
+	if s.w != nil {
+		if closeErr := s.w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if s.file != nil {
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+		if !s.renamed {
+			os.Remove(s.file.Name())
+		}
+	}
+	return err
+}
+
+// readChunkFile reads a chunk file written by the extract command in any format,
+// decrypting and decompressing it as needed.
+func readChunkFile(path string, key []byte) ([]extract.ChromaDocument, error) {
+	data, err := readArtifact(path, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if bytes.HasPrefix(data, parquetMagic) {
+		chunks, err := readParquetChunks(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode chunks in %s: %w", path, err)
+		}
+		return chunks, nil
+	}
+	if data, err = decompressData(data); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if outputFormat("", path) == formatPB {
+		chunks, err := readProtoChunks(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode chunks in %s: %w", path, err)
+		}
+		return chunks, nil
+	}
+	var chunks []extract.ChromaDocument
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &chunks); err != nil {
+			return nil, fmt.Errorf("failed to decode chunks in %s: %w", path, err)
+		}
+		return chunks, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var chunk extract.ChromaDocument
+		if err := decoder.Decode(&chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode chunk %d in %s: %w", len(chunks)+1, path, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// jsonFileSink writes chunks to a JSON file as a single array. Chunks are collected and
+// the file is replaced atomically on Flush, so readers never see a partial array.
+type jsonFileSink struct {
+	path        string
+	key         []byte
+	compression string
+	chunks      []extract.ChromaDocument
+	flushed     bool // Nothing was written since the last flush.
+}
+
+// newJSONFileSink returns a sink writing path, encrypted when key is set.
+func newJSONFileSink(path string, key []byte) *jsonFileSink {
+	return &jsonFileSink{path: path, key: key, chunks: []extract.ChromaDocument{}}
+}
+
+func (s *jsonFileSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	s.chunks = append(s.chunks, chunks...)
+	s.flushed = false
+	return nil
+}
+
+func (s *jsonFileSink) Flush(ctx context.Context) error {
+	if s.flushed {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.chunks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if data, err = compressData(data, s.compression); err != nil {
+		return err
+	}
+	if err := writeArtifact(s.path, data, s.key); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	s.flushed = true
+	return nil
+}
+
+func (s *jsonFileSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// Abort drops the chunks written since the last flush, leaving the file as it was.
+func (s *jsonFileSink) Abort() error {
+	s.chunks, s.flushed = nil, true
+	return nil
+}
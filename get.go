@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// positionalIDPattern splits a positionalChunkID into file, line span and name.
+var positionalIDPattern = regexp.MustCompile(`^(.*):(\d+)-(\d+)-(.+)$`)
+
+// Statuses reported by getChunks for each requested ID.
+const (
+	getStatusCurrent  = "current"   // The ID still names the same declaration.
+	getStatusMoved    = "moved"     // The declaration now has a different ID (lines shifted).
+	getStatusNotFound = "not_found" // The declaration no longer exists.
+)
+
+// FetchedChunk is the answer for one requested chunk ID.
+type FetchedChunk struct {
+	RequestedID string                  `json:"requested_id"`
+	Status      string                  `json:"status"`
+	Chunk       *extract.ChromaDocument `json:"chunk,omitempty"`
+}
+
+// getChunks re-extracts just the packages containing the requested chunks and returns
+// their current versions. Positional IDs go stale as lines shift; a chunk whose ID no
+// longer exists is matched again by the qualified name remembered in state (if given),
+// then by file and entity name.
+func getChunks(ctx context.Context, projectPath string, ids []string, opts extract.ExtractOptions, state *ExtractionState) ([]FetchedChunk, error) {
+	var patterns []string
+	seenPattern := make(map[string]bool)
+	for _, id := range ids {
+		candidates := chunkIDPackages(id)
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("unrecognized chunk ID %q", id)
+		}
+		for _, pattern := range candidates {
+			if !seenPattern[pattern] {
+				seenPattern[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	opts.Packages = patterns
+	result, err := extract.ExtractProject(ctx, projectPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*extract.ChromaDocument, len(result.Chunks))
+	byQualifiedName := make(map[string]*extract.ChromaDocument)
+	byFileAndName := make(map[string]*extract.ChromaDocument)
+	for i := range result.Chunks {
+		chunk := &result.Chunks[i]
+		byID[chunk.ID] = chunk
+		if qualified, _ := chunk.Metadata["qualified_name"].(string); qualified != "" {
+			byQualifiedName[qualified] = chunk
+		}
+		filePath, _ := chunk.Metadata["file_path"].(string)
+		positionalID, _ := chunk.Metadata["positional_id"].(string)
+		if m := positionalIDPattern.FindStringSubmatch(positionalID); m != nil {
+			byFileAndName[filePath+"\x00"+m[4]] = chunk
+		}
+	}
+
+	fetched := make([]FetchedChunk, 0, len(ids))
+	for _, id := range ids {
+		answer := FetchedChunk{RequestedID: id, Status: getStatusNotFound}
+		if chunk, ok := byID[id]; ok {
+			answer.Status, answer.Chunk = getStatusCurrent, chunk
+		} else if chunk := relocateChunk(id, state, byQualifiedName, byFileAndName); chunk != nil {
+			answer.Status, answer.Chunk = getStatusMoved, chunk
+		}
+		fetched = append(fetched, answer)
+	}
+	return fetched, nil
+}
+
+// chunkIDPackages returns the package patterns to load to re-extract the chunk with the
+// given ID: the file of a positional ID, the package of a per-package or per-file chunk
+// ("<package>:package_summary", "<package>:api_digest", "<package>:file:<name>") and the
+// candidate packages of a symbol ID, including the IDs of chunks derived from a symbol
+// ("$n" closures, "~partN" parts, "#group" groups, ":embed:" assets). An external test
+// package is loaded with the package it tests. Parts and groups are only re-created when
+// get is given the -split-tokens, -split-overlap and -group-tokens they were extracted with.
+func chunkIDPackages(id string) []string {
+	if m := positionalIDPattern.FindStringSubmatch(id); m != nil {
+		return []string{"file=" + m[1]}
+	}
+	// Import paths and symbols contain no colons.
+	base, suffix, hasSuffix := strings.Cut(id, ":")
+	var candidates []string
+	if hasSuffix && (suffix == "package_summary" || suffix == "api_digest" || strings.HasPrefix(suffix, "file:")) {
+		candidates = []string{base}
+	} else {
+		candidates = symbolIDPackages(base)
+	}
+	for _, candidate := range candidates {
+		if tested := strings.TrimSuffix(candidate, "_test"); tested != candidate {
+			candidates = append(candidates, tested)
+		}
+	}
+	return candidates
+}
+
+// symbolIDPackages returns the import paths a symbol chunk ID ("example.com/app/pkg.Type.Method")
+// may belong to. Dots in the last path element make the split ambiguous
+// ("gopkg.in/yaml.v3.Marshal"), so every candidate is returned.
+func symbolIDPackages(id string) []string {
+	if i := strings.IndexAny(id, "@#$~"); i >= 0 {
+		id = id[:i]
+	}
+	lastSlash := strings.LastIndex(id, "/")
+	var candidates []string
+	for i := lastSlash + 1; i < len(id); i++ {
+		if id[i] == '.' && i > lastSlash+1 {
+			candidates = append(candidates, id[:i])
+		}
+	}
+	return candidates
+}
+
+func relocateChunk(id string, state *ExtractionState, byQualifiedName, byFileAndName map[string]*extract.ChromaDocument) *extract.ChromaDocument {
+	if state != nil {
+		if previous, ok := state.Chunks[id]; ok && previous.QualifiedName != "" {
+			if chunk, ok := byQualifiedName[previous.QualifiedName]; ok {
+				return chunk
+			}
+		}
+	}
+	if m := positionalIDPattern.FindStringSubmatch(id); m != nil {
+		return byFileAndName[m[1]+"\x00"+m[4]]
+	}
+	return nil
+}
+
+// runGet implements the "get" subcommand: get [-ids id1,id2,...] [id...] prints the
+// current version of each chunk as JSON.
+func runGet(args []string) ([]FetchedChunk, error) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	projectFlag := fs.String("project", ".", "Root directory of the Go module or workspace")
+	idList := fs.String("ids", "", "Comma-separated chunk IDs to fetch; IDs containing commas (positional IDs of grouped values) must be given as arguments")
+	buildTags := fs.String("tags", "", "Comma-separated build tags the chunks were extracted with")
+	tests := fs.Bool("tests", false, "Also look up chunks of _test.go files (implied by IDs of external test packages)")
+	statePath := fs.String("state", ".chroma-extract-state.json", "Incremental state file used to relocate chunks whose IDs moved")
+	encryptKeyFile := fs.String("encrypt-key-file", "", "Key for an encrypted state file (or set $"+encryptionKeyEnv+")")
+	splitTokens := fs.Int("split-tokens", 0, "The -split-tokens the chunks were extracted with, to look up function parts (<id>~partN)")
+	splitOverlap := fs.Int("split-overlap", 0, "The -split-overlap the chunks were extracted with")
+	groupTokens := fs.Int("group-tokens", 0, "The -group-tokens the chunks were extracted with, to look up grouped values (<id>#group)")
+	fs.Parse(args)
+
+	projectPath, err := extract.ValidateProjectPath(*projectFlag)
+	if err != nil {
+		return nil, err
+	}
+	ids := append(splitList(*idList), fs.Args()...)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no chunk IDs given; use -ids id1,id2,... or pass them as arguments")
+	}
+	key, err := loadEncryptionKey(*encryptKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadExtractionState(*statePath, key)
+	if err != nil {
+		return nil, err
+	}
+	opts := extract.DefaultExtractOptions()
+	opts.BuildTags = splitList(*buildTags)
+	opts.SplitTokens, opts.SplitOverlap = *splitTokens, *splitOverlap
+	opts.GroupTokens = *groupTokens
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.Tests = *tests
+	for _, id := range ids {
+		for _, pattern := range chunkIDPackages(id) {
+			opts.Tests = opts.Tests || strings.HasSuffix(pattern, "_test")
+		}
+	}
+	// Chunks that options only add next to the declaration chunks are all produced, so
+	// their IDs resolve whichever of those options the chunks were extracted with.
+	opts.APIDigest, opts.PackageSummary = true, true
+	opts.Granularity = extract.GranularityBoth
+	opts.InterfaceMethodChunks, opts.EmbedAssets = true, true
+	opts.ClosureTokens = 1
+	return getChunks(context.Background(), projectPath, ids, opts, state)
+}
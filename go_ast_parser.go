@@ -1,3 +1,9 @@
+//go:build ignore
+
+// Legacy functions-only extractor, superseded by allsymbols.go. It declares its own
+// main and helpers, so it is excluded from the package build; run it directly with
+// `go run go_ast_parser.go`.
+
 package main
 
 import (
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// extractionStateVersion is bumped whenever the fingerprint computation changes, which
+// forces a full re-emit on the next incremental run.
+const extractionStateVersion = 2
+
+// ExtractionState is persisted between incremental runs. It remembers a fingerprint per
+// emitted chunk plus the bits of metadata other chunks depend on.
+type ExtractionState struct {
+	Version int                   `json:"version"`
+	Chunks  map[string]ChunkState `json:"chunks"`
+}
+
+// ChunkState is the remembered state of one chunk, keyed by chunk ID.
+type ChunkState struct {
+	Fingerprint   string `json:"fingerprint"`
+	QualifiedName string `json:"qualified_name,omitempty"`
+	Signature     string `json:"signature,omitempty"`
+}
+
+// IncrementalResult is the outcome of comparing the current chunk set with the saved state.
+type IncrementalResult struct {
+	Changed    []extract.ChromaDocument // New or modified chunks, plus chunks affected by them.
+	DeletedIDs []string                 // IDs present in the previous run but not in this one.
+	Unchanged  int
+	Dependents int // Unchanged chunks re-emitted because a callee's signature changed.
+}
+
+// positionalMetadataKeys are the metadata fields that change whenever code above a chunk
+// is edited. They are left out of fingerprints, so moving a declaration does not re-emit it.
+var positionalMetadataKeys = []string{"start_line", "end_line", "declaration_order", "positional_id", "method_locations", "source_locations"}
+
+// chunkFingerprint hashes a chunk's ID, document and position-independent metadata.
+// encoding/json sorts map keys, so the result is deterministic across runs.
+func chunkFingerprint(chunk extract.ChromaDocument) (string, error) {
+	metadata := make(map[string]interface{}, len(chunk.Metadata))
+	for k, v := range chunk.Metadata {
+		metadata[k] = v
+	}
+	for _, key := range positionalMetadataKeys {
+		delete(metadata, key)
+	}
+	data, err := json.Marshal(extract.ChromaDocument{ID: chunk.ID, Document: chunk.Document, Metadata: metadata})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadExtractionState reads the state file. A missing file yields an empty state, so the
+// first incremental run emits everything.
+func loadExtractionState(path string, key []byte) (*ExtractionState, error) {
+	state := &ExtractionState{Version: extractionStateVersion, Chunks: make(map[string]ChunkState)}
+	data, err := readArtifact(path, key)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	var saved ExtractionState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if saved.Version != extractionStateVersion || saved.Chunks == nil {
+		log.Printf("State file %s has version %d (want %d); re-emitting all chunks.", path, saved.Version, extractionStateVersion)
+		return state, nil
+	}
+	return &saved, nil
+}
+
+// saveExtractionState writes the state file atomically, so an interrupted run never
+// leaves a truncated state behind. It is encrypted like the other artifacts when key is set.
+func saveExtractionState(path string, state *ExtractionState, key []byte) error {
+	if err := writeJSONFileAtomic(path, state, key); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// diffAgainstState compares the freshly extracted chunks with the previous state at the
+// granularity of individual symbols: only chunks whose document or metadata changed are
+// returned, together with unchanged chunks that call a symbol whose signature changed
+// (their call-graph context is stale). The returned state describes the current chunk set.
+func diffAgainstState(chunks []extract.ChromaDocument, previous *ExtractionState) (IncrementalResult, *ExtractionState, error) {
+	var result IncrementalResult
+	current := &ExtractionState{Version: extractionStateVersion, Chunks: make(map[string]ChunkState, len(chunks))}
+
+	changed := make([]bool, len(chunks))
+	changedSignatures := make(map[string]bool)
+
+	for i, chunk := range chunks {
+		fingerprint, err := chunkFingerprint(chunk)
+		if err != nil {
+			return result, nil, fmt.Errorf("failed to fingerprint chunk %s: %w", chunk.ID, err)
+		}
+		entry := ChunkState{Fingerprint: fingerprint}
+		entry.QualifiedName, _ = chunk.Metadata["qualified_name"].(string)
+		entry.Signature, _ = chunk.Metadata["signature"].(string)
+		current.Chunks[chunk.ID] = entry
+
+		old, existed := previous.Chunks[chunk.ID]
+		if existed && old.Fingerprint == fingerprint {
+			continue
+		}
+		changed[i] = true
+		if existed && entry.QualifiedName != "" && old.Signature != entry.Signature {
+			changedSignatures[entry.QualifiedName] = true
+		}
+	}
+
+	// Symbols that disappeared also invalidate their callers.
+	for id, old := range previous.Chunks {
+		if _, stillExists := current.Chunks[id]; !stillExists {
+			result.DeletedIDs = append(result.DeletedIDs, id)
+			if old.QualifiedName != "" {
+				changedSignatures[old.QualifiedName] = true
+			}
+		}
+	}
+	sort.Strings(result.DeletedIDs)
+
+	for i, chunk := range chunks {
+		if !changed[i] && callsAny(chunk, changedSignatures) {
+			changed[i] = true
+			result.Dependents++
+		}
+		if changed[i] {
+			result.Changed = append(result.Changed, chunk)
+		} else {
+			result.Unchanged++
+		}
+	}
+	return result, current, nil
+}
+
+// callsAny reports whether the chunk's call edges reference any of the given symbols.
+func callsAny(chunk extract.ChromaDocument, symbols map[string]bool) bool {
+	if len(symbols) == 0 {
+		return false
+	}
+	callees, _ := chunk.Metadata["calls"].([]string)
+	for _, callee := range callees {
+		if symbols[callee] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyIncrementalState loads the previous state and diffs the chunk set against it. It
+// returns the chunks that need to be (re-)emitted and the new state, which the caller
+// saves once they were written, so a failed write is retried on the next run.
+func applyIncrementalState(statePath string, chunks []extract.ChromaDocument, key []byte) (IncrementalResult, *ExtractionState, error) {
+	previous, err := loadExtractionState(statePath, key)
+	if err != nil {
+		return IncrementalResult{}, nil, err
+	}
+	return diffAgainstState(chunks, previous)
+}
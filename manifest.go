@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// Manifest describes one extraction run, so downstream jobs can check that they got
+// every output file intact and that the run was not cut short.
+type Manifest struct {
+	ToolVersion string `json:"tool_version"`
+	// SchemaVersion is the extract.ChunkSchemaVersion of the chunks' metadata.
+	SchemaVersion int       `json:"schema_version"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	// Complete is false when packages were skipped because the time budget ran out.
+	Complete        bool     `json:"complete"`
+	SkippedPackages []string `json:"skipped_packages,omitempty"`
+	// TotalChunks counts the chunks written; with -incremental only the changed ones.
+	TotalChunks int               `json:"total_chunks"`
+	TotalBytes  int64             `json:"total_bytes"`
+	Outputs     []ManifestOutput  `json:"outputs"`
+	Packages    []ManifestPackage `json:"packages"`
+}
+
+// ManifestOutput is one written chunk file.
+type ManifestOutput struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestPackage is one processed package: its chunk count and the SHA-256 of each
+// source file it was extracted from.
+type ManifestPackage struct {
+	Path   string            `json:"path"`
+	Chunks int               `json:"chunks"`
+	Files  map[string]string `json:"files"`
+}
+
+// toolVersion identifies the extractor build: its module version and, for builds from
+// a checkout, the VCS revision.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" {
+		version += " " + revision
+		if modified {
+			version += "+dirty"
+		}
+	}
+	return version
+}
+
+// fileHash returns the size and hex SHA-256 of a file.
+func fileHash(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// outputFiles lists the chunk files written for out: OutputFile or its shards. Output
+// to stdout or to a custom sink has no files.
+func outputFiles(out OutputOptions) []string {
+	if out.Sink != nil || out.OutputFile == stdoutOutput {
+		return nil
+	}
+	if out.MaxChunksPerFile == 0 && out.MaxBytesPerFile == 0 {
+		return []string{out.OutputFile}
+	}
+	var files []string
+	for shard := 1; ; shard++ {
+		path := shardPath(out.OutputFile, shard)
+		if _, err := os.Stat(path); err != nil {
+			return files
+		}
+		files = append(files, path)
+	}
+}
+
+// manifestPath is where the manifest of out is written, or "" for none.
+func manifestPath(out OutputOptions) string {
+	if !out.Manifest {
+		return ""
+	}
+	if out.ManifestFile != "" {
+		return out.ManifestFile
+	}
+	if out.Sink != nil || out.OutputFile == stdoutOutput {
+		return ""
+	}
+	return out.OutputFile + ".manifest.json"
+}
+
+// emitManifest writes the manifest of a run that wrote chunks chunks, if requested.
+func emitManifest(result *extract.ExtractionResult, chunks int, out OutputOptions) error {
+	path := manifestPath(out)
+	if path == "" {
+		return nil
+	}
+	manifest := Manifest{
+		ToolVersion:   toolVersion(),
+		SchemaVersion: extract.ChunkSchemaVersion,
+		StartedAt:     result.StartedAt,
+		FinishedAt:    time.Now().UTC(),
+		TotalChunks:   chunks,
+		Outputs:       []ManifestOutput{},
+		Packages:      []ManifestPackage{},
+	}
+	for _, file := range outputFiles(out) {
+		size, hash, err := fileHash(file)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+		manifest.Outputs = append(manifest.Outputs, ManifestOutput{Path: file, Bytes: size, SHA256: hash})
+		manifest.TotalBytes += size
+	}
+	for _, stats := range result.Packages {
+		if stats.Skipped {
+			manifest.SkippedPackages = append(manifest.SkippedPackages, stats.Path)
+			continue
+		}
+		files := stats.FileHashes
+		if files == nil {
+			files = map[string]string{}
+		}
+		manifest.Packages = append(manifest.Packages, ManifestPackage{Path: stats.Path, Chunks: stats.Chunks, Files: files})
+	}
+	sort.Slice(manifest.Packages, func(i, j int) bool { return manifest.Packages[i].Path < manifest.Packages[j].Path })
+	manifest.Complete = len(manifest.SkippedPackages) == 0
+	if err := writeJSONFileAtomic(path, manifest, out.EncryptionKey); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if !manifest.Complete {
+		fmt.Fprintf(out.messages(), "Manifest: run incomplete, %d packages skipped. Details in %s\n", len(manifest.SkippedPackages), path)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// embeddingProviderOllama uses the native embedding API of an Ollama server.
+	embeddingProviderOllama = "ollama"
+	defaultOllamaURL        = "http://localhost:11434"
+)
+
+// ollamaEmbedder computes embeddings with Ollama's /api/embed endpoint.
+type ollamaEmbedder struct {
+	baseURL    string
+	model      string
+	dimensions int
+	http       *http.Client
+}
+
+func newOllamaEmbedder(opts EmbeddingOptions) (EmbeddingProvider, error) {
+	if opts.URL == "" {
+		opts.URL = defaultOllamaURL
+	}
+	return &ollamaEmbedder{
+		baseURL:    strings.TrimRight(opts.URL, "/"),
+		model:      opts.Model,
+		dimensions: opts.Dimensions,
+		http:       &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Embed returns the embeddings of texts, in order.
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	request := map[string]interface{}{"model": e.model, "input": texts}
+	if e.dimensions > 0 {
+		request["dimensions"] = e.dimensions
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, newEmbeddingHTTPError("Ollama", resp, strings.TrimSpace(string(body)))
+	}
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama returned %d embeddings for %d inputs", len(response.Embeddings), len(texts))
+	}
+	return response.Embeddings, nil
+}
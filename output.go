@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// OutputOptions controls where and how an extracted chunk set is written.
+type OutputOptions struct {
+	OutputFile string
+	// Format is formatJSON, formatJSONL, formatParquet, formatCSV, formatPB (see
+	// chunk.proto) or formatBulk and Compression compressNone,
+	// compressGzip or compressZstd; "" picks them by OutputFile's extensions (e.g.
+	// .jsonl.zst). Parquet output compresses its pages instead of the whole file.
+	Format      string
+	Compression string
+	// CSVColumns are the metadata columns of CSV output (defaultCSVColumns if empty).
+	CSVColumns []string
+	// MaxChunksPerFile and MaxBytesPerFile, if set, split the chunk output into numbered
+	// shard files (see shardedSink).
+	MaxChunksPerFile int
+	MaxBytesPerFile  int64
+	// EntrypointsFile receives the chunks of main/cmd packages split off by -skip-main and
+	// -skip-cmd, as a separate collection. It is always written in full.
+	EntrypointsFile string
+	// Dedupe emits chunks with identical text once (see dedupeChunks).
+	Dedupe bool
+	// Incremental writes only chunks that changed since the run recorded in StatePath.
+	Incremental bool
+	StatePath   string
+	// SizeReportPath, if set, receives a chunk-size report of the full chunk set.
+	SizeReportPath string
+	SizeReportTop  int
+	TokenLimit     int
+	// HealthReportPath, if set, receives the import-cycle and package health report.
+	HealthReportPath string
+	HealthThresholds extract.HealthThresholds
+	// EncryptionKey, if set, encrypts every artifact (chunks, deleted IDs, reports and
+	// incremental state) with AES-256-GCM.
+	EncryptionKey []byte
+	// Sink, if set, receives the chunks instead of a JSON file at OutputFile.
+	Sink extract.Sink
+	// Embedder, if set, computes the embedding of every chunk before it is written,
+	// EmbeddingBatchSize chunks per request with up to EmbeddingConcurrency requests in
+	// flight.
+	Embedder             EmbeddingProvider
+	EmbeddingBatchSize   int
+	EmbeddingConcurrency int
+	// Manifest writes a manifest of the run (see Manifest) to ManifestFile, by default
+	// OutputFile + ".manifest.json".
+	Manifest     bool
+	ManifestFile string
+}
+
+// stdoutOutput as OutputFile writes the chunks to stdout, as JSONL unless another
+// format is selected.
+const stdoutOutput = "-"
+
+// defaultOutputFile is where chunks are written when -out is not given.
+const defaultOutputFile = "code_chunks_test.json"
+
+// messages is where progress messages go: stderr when the chunks go to stdout.
+func (out OutputOptions) messages() io.Writer {
+	if out.OutputFile == stdoutOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// destination describes where the chunks go in messages.
+func (out OutputOptions) destination() string {
+	if out.Sink != nil {
+		if name, ok := out.Sink.(fmt.Stringer); ok {
+			return name.String()
+		}
+		return "the configured sink"
+	}
+	if out.OutputFile == stdoutOutput {
+		return "stdout"
+	}
+	if out.MaxChunksPerFile > 0 || out.MaxBytesPerFile > 0 {
+		return "shard files starting with " + shardPath(out.OutputFile, 1)
+	}
+	return out.OutputFile
+}
+
+// emitExtraction writes the chunks of an extraction run and, if requested, its health
+// report and manifest.
+func emitExtraction(ctx context.Context, result *extract.ExtractionResult, out OutputOptions) error {
+	emitted, err := emitChunks(ctx, result.Chunks, out)
+	if err != nil {
+		return err
+	}
+	if out.EntrypointsFile != "" {
+		entrypoints := result.Entrypoints
+		if entrypoints == nil {
+			entrypoints = []extract.ChromaDocument{}
+		}
+		if err := writeJSONFileAtomic(out.EntrypointsFile, entrypoints, out.EncryptionKey); err != nil {
+			return fmt.Errorf("failed to write entrypoint chunks: %w", err)
+		}
+		fmt.Fprintf(out.messages(), "Wrote %d entrypoint chunks to %s\n", len(entrypoints), out.EntrypointsFile)
+	}
+	if err := emitHealthReport(result.Packages, out); err != nil {
+		return err
+	}
+	return emitManifest(result, emitted, out)
+}
+
+// emitHealthReport writes the health report of the extracted packages, if requested.
+func emitHealthReport(packages []extract.PackageStats, out OutputOptions) error {
+	if out.HealthReportPath == "" {
+		return nil
+	}
+	report := extract.BuildHealthReport(packages, out.HealthThresholds)
+	if err := writeJSONFileAtomic(out.HealthReportPath, report, out.EncryptionKey); err != nil {
+		return fmt.Errorf("failed to write health report: %w", err)
+	}
+	fmt.Fprintf(out.messages(), "Health report: %d packages, %d import cycles, %d oversized, %d with errors. Details in %s\n",
+		report.TotalPackages, len(report.ImportCycles), len(report.Oversized), report.PackagesWithErrors, out.HealthReportPath)
+	return nil
+}
+
+// emitChunks writes a freshly extracted chunk set according to the output options and
+// returns the number of chunks written. Files are replaced atomically, so readers never
+// observe a partially written output.
+func emitChunks(ctx context.Context, chunks []extract.ChromaDocument, out OutputOptions) (int, error) {
+	// Duplicates are dropped first, so they are neither embedded nor tracked as changes.
+	if out.Dedupe {
+		var removed int
+		chunks, removed = dedupeChunks(chunks)
+		log.Printf("Deduplicated %d chunks with identical text.", removed)
+	}
+	// In incremental mode only changed symbols (and their affected callers) are written;
+	// the full chunk set is still used for reporting below.
+	emitted := chunks
+	var state *ExtractionState
+	if out.Incremental {
+		result, current, err := applyIncrementalState(out.StatePath, chunks, out.EncryptionKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to apply incremental state: %w", err)
+		}
+		emitted, state = result.Changed, current
+		log.Printf("Incremental run: %d changed (%d re-emitted for changed callees), %d unchanged, %d deleted.",
+			len(result.Changed), result.Dependents, result.Unchanged, len(result.DeletedIDs))
+		if len(result.DeletedIDs) > 0 {
+			deletedFileName := out.OutputFile + ".deleted.json"
+			if err := writeJSONFileAtomic(deletedFileName, result.DeletedIDs, out.EncryptionKey); err != nil {
+				return 0, fmt.Errorf("failed to write deleted IDs: %w", err)
+			}
+			log.Printf("Wrote %d deleted chunk IDs to %s", len(result.DeletedIDs), deletedFileName)
+		}
+	}
+
+	sink := out.sink()
+	if err := sink.Write(ctx, emitted); err != nil {
+		extract.AbortSink(sink)
+		return 0, err
+	}
+	if err := sink.Close(); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(out.messages(), "Successfully extracted %d code chunks to %s\n", len(emitted), out.destination())
+	if state != nil {
+		if err := saveExtractionState(out.StatePath, state, out.EncryptionKey); err != nil {
+			return 0, err
+		}
+	}
+
+	if out.SizeReportPath != "" {
+		report := buildChunkSizeReport(chunks, out.TokenLimit, out.SizeReportTop)
+		if err := writeChunkSizeReport(out.SizeReportPath, report, out.EncryptionKey); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(out.messages(), "Size report: %d of %d chunks exceed %d tokens (largest: %d tokens). Details in %s\n",
+			report.ChunksOverLimit, report.TotalChunks, report.TokenLimit, report.MaxTokens, out.SizeReportPath)
+	}
+	return len(emitted), nil
+}
+
+// sink returns out.Sink or else the sink writing OutputFile, behind the embedding stage
+// when out.Embedder is set.
+func (out OutputOptions) sink() extract.Sink {
+	sink := out.Sink
+	if sink == nil {
+		sink = newFileSink(out.OutputFile, out)
+	}
+	if out.Embedder != nil {
+		batchSize := out.EmbeddingBatchSize
+		if batchSize <= 0 {
+			batchSize = defaultEmbeddingBatchSize
+		}
+		concurrency := out.EmbeddingConcurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		sink = &embeddingSink{next: sink, embedder: out.Embedder, batchSize: batchSize, concurrency: concurrency}
+	}
+	return sink
+}
+
+// canStreamOutput reports whether chunks can be written while they are extracted
+// rather than after the whole chunk set is collected. This needs a sink or a format
+// written row by row (anything but JSON) and none of the features that work on the full
+// set: deduplication, incremental diffing, merging chunks across targets, separate
+// entrypoint output and the size report.
+func canStreamOutput(opts extract.ExtractOptions, out OutputOptions) bool {
+	format := outputFormat(out.Format, out.OutputFile)
+	return (out.Sink != nil || format != formatJSON) &&
+		out.EncryptionKey == nil && !out.Dedupe && !out.Incremental && len(opts.Targets) <= 1 &&
+		out.EntrypointsFile == "" && out.SizeReportPath == ""
+}
+
+// streamExtraction extracts projectPaths straight into the output file, one package at
+// a time, so memory stays flat however large the projects are. It produces the same
+// chunks and health report as ExtractProjects followed by emitExtraction; see
+// canStreamOutput for when it can be used.
+func streamExtraction(ctx context.Context, projectPaths []string, opts extract.ExtractOptions, out OutputOptions) error {
+	started := time.Now().UTC()
+	target := extract.HostTarget()
+	if len(opts.Targets) == 1 {
+		opts.Target = opts.Targets[0]
+		target = opts.Target
+	}
+	sink := out.sink()
+	names := extract.ProjectNames(projectPaths)
+	var packages []extract.PackageStats
+	written := 0
+	for _, projectPath := range projectPaths {
+		id := extract.ProjectID(projectPath)
+		stats, err := extract.StreamProject(ctx, projectPath, opts, func(chunk extract.ChromaDocument, entrypoint bool) error {
+			if entrypoint {
+				return nil
+			}
+			chunk.Metadata["targets"] = []string{target}
+			chunk.Metadata["project"] = names[projectPath]
+			chunk.Metadata["project_id"] = id
+			written++
+			return sink.Write(ctx, []extract.ChromaDocument{chunk})
+		})
+		packages = append(packages, stats...)
+		if err != nil {
+			extract.AbortSink(sink)
+			return fmt.Errorf("project %s: %w", projectPath, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out.messages(), "Successfully extracted %d code chunks to %s\n", written, out.destination())
+	if err := emitHealthReport(packages, out); err != nil {
+		return err
+	}
+	return emitManifest(&extract.ExtractionResult{Packages: packages, StartedAt: started}, written, out)
+}
+
+// writeJSONFileAtomic marshals v as indented JSON and writes it with writeArtifact.
+func writeJSONFileAtomic(path string, v interface{}, key []byte) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return writeArtifact(path, jsonData, key)
+}
+
+// writeArtifact encrypts data when a key is given, writes it into a temporary file in the
+// target's directory and renames it into place, which is atomic on POSIX filesystems.
+// The path "-" writes to stdout.
+func writeArtifact(path string, data []byte, key []byte) error {
+	if key != nil {
+		encrypted, err := encryptArtifact(data, key)
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+	if path == stdoutOutput {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// parquetChunkRow is the column schema of Parquet output: the chunk ID and text, one
+// column per ChunkMetadata core field, and the remaining metadata as a JSON object in
+// metadata_extra, plus the embedding if computed. Columns are only ever added, so
+// pipelines can rely on the schema. The metadata schema version is stored once, as the
+// "schema_version" key of the file metadata.
+type parquetChunkRow struct {
+	ID              string    `parquet:"id"`
+	Document        string    `parquet:"document"`
+	FilePath        string    `parquet:"file_path"`
+	PackageName     string    `parquet:"package_name"`
+	PackagePath     string    `parquet:"package_path"`
+	EntityType      string    `parquet:"entity_type"`
+	EntityName      string    `parquet:"entity_name"`
+	QualifiedName   string    `parquet:"qualified_name"`
+	StartLine       int32     `parquet:"start_line"`
+	EndLine         int32     `parquet:"end_line"`
+	Signature       string    `parquet:"signature"`
+	ReceiverType    string    `parquet:"receiver_type"`
+	DeclarationKind string    `parquet:"declaration_kind"`
+	TypeCategory    string    `parquet:"type_category"`
+	Typed           bool      `parquet:"typed"`
+	IsInternal      bool      `parquet:"is_internal"`
+	Visibility      string    `parquet:"visibility"`
+	License         string    `parquet:"license"`
+	ModulePath      string    `parquet:"module_path"`
+	ModuleVersion   string    `parquet:"module_version"`
+	GoVersion       string    `parquet:"go_version"`
+	Project         string    `parquet:"project"`
+	Targets         []string  `parquet:"targets,list"`
+	Calls           []string  `parquet:"calls,list"`
+	MetadataExtra   string    `parquet:"metadata_extra"`
+	Embedding       []float32 `parquet:"embedding,list"`
+}
+
+// newParquetChunkRow flattens a chunk into a row.
+func newParquetChunkRow(chunk extract.ChromaDocument) (parquetChunkRow, error) {
+	meta, err := chunk.TypedMetadata()
+	if err != nil {
+		return parquetChunkRow{}, err
+	}
+	extra, err := json.Marshal(meta.Extra)
+	if err != nil {
+		return parquetChunkRow{}, fmt.Errorf("failed to marshal metadata of chunk %s: %w", chunk.ID, err)
+	}
+	if meta.Extra == nil {
+		extra = []byte("{}")
+	}
+	return parquetChunkRow{
+		ID:              chunk.ID,
+		Document:        chunk.Document,
+		FilePath:        meta.FilePath,
+		PackageName:     meta.PackageName,
+		PackagePath:     meta.PackagePath,
+		EntityType:      meta.EntityType,
+		EntityName:      meta.EntityName,
+		QualifiedName:   meta.QualifiedName,
+		StartLine:       int32(meta.StartLine),
+		EndLine:         int32(meta.EndLine),
+		Signature:       meta.Signature,
+		ReceiverType:    meta.ReceiverType,
+		DeclarationKind: meta.DeclarationKind,
+		TypeCategory:    meta.TypeCategory,
+		Typed:           meta.Typed,
+		IsInternal:      meta.IsInternal,
+		Visibility:      meta.Visibility,
+		License:         meta.License,
+		ModulePath:      meta.ModulePath,
+		ModuleVersion:   meta.ModuleVersion,
+		GoVersion:       meta.GoVersion,
+		Project:         meta.Project,
+		Targets:         meta.Targets,
+		Calls:           meta.Calls,
+		MetadataExtra:   string(extra),
+		Embedding:       chunk.Embedding,
+	}, nil
+}
+
+// chunk converts a row back into a chunk. Empty core fields are omitted from the
+// metadata, like in the JSON output.
+func (row parquetChunkRow) chunk() (extract.ChromaDocument, error) {
+	meta := extract.ChunkMetadata{
+		FilePath:        row.FilePath,
+		PackageName:     row.PackageName,
+		PackagePath:     row.PackagePath,
+		EntityType:      row.EntityType,
+		EntityName:      row.EntityName,
+		QualifiedName:   row.QualifiedName,
+		StartLine:       int(row.StartLine),
+		EndLine:         int(row.EndLine),
+		Signature:       row.Signature,
+		ReceiverType:    row.ReceiverType,
+		DeclarationKind: row.DeclarationKind,
+		TypeCategory:    row.TypeCategory,
+		Typed:           row.Typed,
+		IsInternal:      row.IsInternal,
+		Visibility:      row.Visibility,
+		License:         row.License,
+		ModulePath:      row.ModulePath,
+		ModuleVersion:   row.ModuleVersion,
+		GoVersion:       row.GoVersion,
+		Project:         row.Project,
+		Targets:         row.Targets,
+		Calls:           row.Calls,
+	}
+	if err := json.Unmarshal([]byte(row.MetadataExtra), &meta.Extra); err != nil {
+		return extract.ChromaDocument{}, fmt.Errorf("invalid metadata_extra of chunk %s: %w", row.ID, err)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return extract.ChromaDocument{}, err
+	}
+	chunk := extract.ChromaDocument{ID: row.ID, Document: row.Document}
+	if len(row.Embedding) > 0 {
+		chunk.Embedding = row.Embedding
+	}
+	if err := json.Unmarshal(data, &chunk.Metadata); err != nil {
+		return extract.ChromaDocument{}, err
+	}
+	return chunk, nil
+}
+
+// parquetCodec returns the Parquet page compression for a -compress value.
+func parquetCodec(compression string) parquet.WriterOption {
+	switch compression {
+	case compressGzip:
+		return parquet.Compression(&parquet.Gzip)
+	case compressZstd:
+		return parquet.Compression(&parquet.Zstd)
+	}
+	return parquet.Compression(&parquet.Uncompressed)
+}
+
+// parquetFileSink writes chunks as Parquet rows to a temporary file next to path, which
+// replaces path (or is copied to stdout) on Close: a Parquet file cannot be read before
+// its footer is written.
+// Flush ends the current row group. Compression applies to the Parquet pages; with an
+// encryption key the finished file is encrypted as a whole.
+type parquetFileSink struct {
+	path        string
+	key         []byte
+	compression string
+
+	file   *os.File
+	writer *parquet.GenericWriter[parquetChunkRow]
+}
+
+// newParquetFileSink returns a sink writing path, encrypted when key is set.
+func newParquetFileSink(path string, key []byte) *parquetFileSink {
+	return &parquetFileSink{path: path, key: key}
+}
+
+func (s *parquetFileSink) open() error {
+	if s.file != nil {
+		return nil
+	}
+	dir, pattern := filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp-*"
+	if s.path == stdoutOutput {
+		dir, pattern = "", "chunks-*.parquet"
+	}
+	file, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	s.file = file
+	s.writer = parquet.NewGenericWriter[parquetChunkRow](file, parquetCodec(s.compression),
+		parquet.KeyValueMetadata("schema_version", strconv.Itoa(extract.ChunkSchemaVersion)))
+	return nil
+}
+
+func (s *parquetFileSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	if err := s.open(); err != nil {
+		return err
+	}
+	rows := make([]parquetChunkRow, 0, len(chunks))
+	for _, chunk := range chunks {
+		row, err := newParquetChunkRow(chunk)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if _, err := s.writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	return nil
+}
+
+func (s *parquetFileSink) Flush(ctx context.Context) error {
+	if s.writer == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	return nil
+}
+
+func (s *parquetFileSink) Close() error {
+	if err := s.open(); err != nil {
+		return err
+	}
+	tmpPath := s.file.Name()
+	defer os.Remove(tmpPath)
+	err := s.writer.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	if s.key != nil || s.path == stdoutOutput {
+		data, err := ioutil.ReadFile(tmpPath)
+		if err != nil {
+			return err
+		}
+		if err := writeArtifact(s.path, data, s.key); err != nil {
+			return fmt.Errorf("failed to write chunks: %w", err)
+		}
+		return nil
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+	return nil
+}
+
+// Abort removes the temporary file, leaving the output as it was.
+func (s *parquetFileSink) Abort() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	os.Remove(s.file.Name())
+	return err
+}
+
+// parquetMagic starts and ends every Parquet file.
+var parquetMagic = []byte("PAR1")
+
+// readParquetChunks decodes Parquet output back into chunks.
+func readParquetChunks(data []byte) ([]extract.ChromaDocument, error) {
+	rows, err := parquet.Read[parquetChunkRow](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]extract.ChromaDocument, 0, len(rows))
+	for _, row := range rows {
+		chunk, err := row.chunk()
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// defaultPGTable is the table chunks are upserted into.
+const defaultPGTable = "go_code_chunks"
+
+// pgvectorSink upserts chunks into a Postgres table (id, document, metadata jsonb,
+// embedding vector) in batches of batchSize. The pgvector extension and the table are
+// created on the first flush; the embedding column gets the dimension of the first
+// embedding, or none when the chunks have no embeddings. Chunks flagged
+// embedding_skipped are left out.
+type pgvectorSink struct {
+	conn      *pgx.Conn
+	table     string // Quoted, possibly schema-qualified.
+	batchSize int
+	pending   []extract.ChromaDocument
+	created   bool
+	batches   int
+}
+
+// newPGVectorSink connects to the database at connURL; unset connection parameters
+// come from the standard PG* environment variables and ~/.pgpass.
+func newPGVectorSink(ctx context.Context, connURL, table string, batchSize int) (*pgvectorSink, error) {
+	if batchSize <= 0 {
+		batchSize = defaultChromaBatchSize
+	}
+	conn, err := pgx.Connect(ctx, connURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	return &pgvectorSink{
+		conn:      conn,
+		table:     pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+		batchSize: batchSize,
+	}, nil
+}
+
+func (s *pgvectorSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	for _, chunk := range chunks {
+		if skipped, _ := chunk.Metadata["embedding_skipped"].(bool); skipped {
+			continue
+		}
+		s.pending = append(s.pending, chunk)
+		if len(s.pending) == s.batchSize {
+			if err := s.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createTable creates the extension and table unless they exist.
+func (s *pgvectorSink) createTable(ctx context.Context) error {
+	if _, err := s.conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+		return fmt.Errorf("failed to create the pgvector extension: %w", err)
+	}
+	vectorType := "vector"
+	if len(s.pending[0].Embedding) > 0 {
+		vectorType = "vector(" + strconv.Itoa(len(s.pending[0].Embedding)) + ")"
+	}
+	statement := "CREATE TABLE IF NOT EXISTS " + s.table + ` (
+	id text PRIMARY KEY,
+	document text NOT NULL,
+	metadata jsonb NOT NULL,
+	embedding ` + vectorType + `
+)`
+	if _, err := s.conn.Exec(ctx, statement); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", s.table, err)
+	}
+	s.created = true
+	return nil
+}
+
+func (s *pgvectorSink) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if !s.created {
+		if err := s.createTable(ctx); err != nil {
+			return err
+		}
+	}
+	s.batches++
+	statement := "INSERT INTO " + s.table + ` (id, document, metadata, embedding) VALUES ($1, $2, $3, $4::vector)
+ON CONFLICT (id) DO UPDATE SET document = EXCLUDED.document, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`
+	batch := &pgx.Batch{}
+	for _, chunk := range s.pending {
+		metadata, err := json.Marshal(chunk.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata of chunk %s: %w", chunk.ID, err)
+		}
+		var embedding *string
+		if len(chunk.Embedding) > 0 {
+			literal := pgvectorLiteral(chunk.Embedding)
+			embedding = &literal
+		}
+		batch.Queue(statement, chunk.ID, chunk.Document, string(metadata), embedding)
+	}
+	if err := s.conn.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert batch %d (%d chunks from %s): %w", s.batches, len(s.pending), s.pending[0].ID, err)
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// pgvectorLiteral formats an embedding as a pgvector text value, e.g. [1,0.5,-2].
+func pgvectorLiteral(embedding []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func (s *pgvectorSink) Close() error {
+	ctx := context.Background()
+	err := s.Flush(ctx)
+	if closeErr := s.conn.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// String describes the destination in messages.
+func (s *pgvectorSink) String() string {
+	config := s.conn.Config()
+	return fmt.Sprintf("Postgres table %s in %s on %s", s.table, config.Database, config.Host)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles are named presets of flag values. Flags given on the command line or in the
+// config file take precedence over the profile.
+var profiles = map[string]map[string]interface{}{
+	// rag-default: chunks tuned for retrieval-augmented generation with an embedding model.
+	"rag-default": {
+		"search-text":       true,
+		"context-header":    true,
+		"retrieval-bundles": true,
+		"quality-guard":     true,
+		"api-digest":        true,
+		"dedent":            true,
+	},
+	// api-surface: library code only, for documentation and API search.
+	"api-surface": {
+		"skip-main":         true,
+		"skip-cmd":          true,
+		"api-digest":        true,
+		"retrieval-bundles": true,
+		"search-text":       true,
+		"context-header":    false,
+		"quality-guard":     true,
+	},
+	// full-graph: every relationship and report, for code-graph analysis.
+	"full-graph": {
+		"api-digest":        true,
+		"retrieval-bundles": true,
+		"context-header":    true,
+		"search-text":       true,
+		"quality-guard":     false,
+		"health-report":     "health_report.json",
+		"size-report":       "chunk_size_report.json",
+	},
+}
+
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile fills in the flags of the named profile that are still unset.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	values, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (want one of %s)", name, strings.Join(profileNames(), ", "))
+	}
+	return applyFlagDefaults(fs, values)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// formatPB writes the binary protobuf encoding of ChunkSet in chunk.proto.
+const formatPB = "pb"
+
+// protoMetadataFields lists the ChunkMetadata fields by JSON name in the order of their
+// field numbers in chunk.proto (index+1); "" marks a reserved number. It may only be
+// appended to.
+var protoMetadataFields = []string{
+	"", "file_path", "package_name", "package_path", "entity_type",
+	"entity_name", "qualified_name", "start_line", "end_line", "signature",
+	"receiver_type", "declaration_kind", "type_category", "typed", "is_internal",
+	"visibility", "license", "module_path", "module_version", "go_version", "project",
+	"targets", "calls",
+}
+
+// Field numbers of chunk.proto outside ChunkMetadata's core fields.
+const (
+	protoChunkSetChunks protowire.Number = 1
+	protoChunkSetSchema protowire.Number = 2
+	protoChunkID        protowire.Number = 1
+	protoChunkDocument  protowire.Number = 2
+	protoChunkMetadata  protowire.Number = 3
+	protoChunkEmbedding protowire.Number = 4
+	protoMetadataExtra  protowire.Number = 24
+	protoMapEntryKey    protowire.Number = 1
+	protoMapEntryValue  protowire.Number = 2
+)
+
+// protoFieldIndexes maps chunk.proto field numbers to ChunkMetadata struct field indexes.
+var protoFieldIndexes = func() map[protowire.Number]int {
+	byName := make(map[string]int)
+	t := reflect.TypeOf(extract.ChunkMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		byName[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = i
+	}
+	indexes := make(map[protowire.Number]int)
+	for i, name := range protoMetadataFields {
+		if name == "" {
+			continue
+		}
+		index, ok := byName[name]
+		if !ok {
+			panic("chunk.proto field " + name + " is not a ChunkMetadata field")
+		}
+		indexes[protowire.Number(i+1)] = index
+	}
+	return indexes
+}()
+
+// encodeProtoChunk returns a chunk as a ChunkSet.chunks field, so that the records of a
+// file concatenate to a ChunkSet.
+func encodeProtoChunk(chunk extract.ChromaDocument) ([]byte, error) {
+	meta, err := chunk.TypedMetadata()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := encodeProtoMetadata(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chunk %s: %w", chunk.ID, err)
+	}
+	var msg []byte
+	msg = appendProtoString(msg, protoChunkID, chunk.ID)
+	msg = appendProtoString(msg, protoChunkDocument, chunk.Document)
+	msg = protowire.AppendTag(msg, protoChunkMetadata, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, metadata)
+	if len(chunk.Embedding) > 0 {
+		var packed []byte
+		for _, f := range chunk.Embedding {
+			packed = protowire.AppendFixed32(packed, math.Float32bits(f))
+		}
+		msg = protowire.AppendTag(msg, protoChunkEmbedding, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, packed)
+	}
+
+	record := protowire.AppendTag(nil, protoChunkSetChunks, protowire.BytesType)
+	return protowire.AppendBytes(record, msg), nil
+}
+
+// protoSchemaHeader is the ChunkSet.schema_version field, written once before the chunks.
+func protoSchemaHeader() []byte {
+	header := protowire.AppendTag(nil, protoChunkSetSchema, protowire.VarintType)
+	return protowire.AppendVarint(header, uint64(extract.ChunkSchemaVersion))
+}
+
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func encodeProtoMetadata(meta extract.ChunkMetadata) ([]byte, error) {
+	var b []byte
+	v := reflect.ValueOf(meta)
+	for num := protowire.Number(1); int(num) <= len(protoMetadataFields); num++ {
+		index, ok := protoFieldIndexes[num]
+		if !ok {
+			continue
+		}
+		field := v.Field(index)
+		switch field.Kind() {
+		case reflect.String:
+			b = appendProtoString(b, num, field.String())
+		case reflect.Int:
+			if field.Int() != 0 {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, uint64(int32(field.Int())))
+			}
+		case reflect.Bool:
+			if field.Bool() {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, 1)
+			}
+		case reflect.Slice:
+			for i := 0; i < field.Len(); i++ {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendString(b, field.Index(i).String())
+			}
+		}
+	}
+	keys := make([]string, 0, len(meta.Extra))
+	for key := range meta.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := json.Marshal(meta.Extra[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata %q: %w", key, err)
+		}
+		var entry []byte
+		entry = appendProtoString(entry, protoMapEntryKey, key)
+		entry = appendProtoString(entry, protoMapEntryValue, string(value))
+		b = protowire.AppendTag(b, protoMetadataExtra, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b, nil
+}
+
+// protoFields calls fn for every field of a protobuf message; bytes fields get their
+// content in data and varints their value in n.
+func protoFields(msg []byte, fn func(num protowire.Number, data []byte, n uint64) error) error {
+	for len(msg) > 0 {
+		num, typ, length := protowire.ConsumeTag(msg)
+		if length < 0 {
+			return protowire.ParseError(length)
+		}
+		msg = msg[length:]
+		var (
+			data []byte
+			n    uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			data, length = protowire.ConsumeBytes(msg)
+		case protowire.VarintType:
+			n, length = protowire.ConsumeVarint(msg)
+		default:
+			length = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if length < 0 {
+			return protowire.ParseError(length)
+		}
+		msg = msg[length:]
+		if err := fn(num, data, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProtoChunks decodes a serialized ChunkSet.
+func readProtoChunks(data []byte) ([]extract.ChromaDocument, error) {
+	var chunks []extract.ChromaDocument
+	err := protoFields(data, func(num protowire.Number, msg []byte, _ uint64) error {
+		if num != protoChunkSetChunks {
+			return nil
+		}
+		chunk, err := decodeProtoChunk(msg)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", len(chunks)+1, err)
+		}
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	return chunks, err
+}
+
+func decodeProtoChunk(msg []byte) (extract.ChromaDocument, error) {
+	var chunk extract.ChromaDocument
+	meta := extract.ChunkMetadata{}
+	metaValue := reflect.ValueOf(&meta).Elem()
+	err := protoFields(msg, func(num protowire.Number, data []byte, _ uint64) error {
+		switch num {
+		case protoChunkID:
+			chunk.ID = string(data)
+		case protoChunkDocument:
+			chunk.Document = string(data)
+		case protoChunkEmbedding:
+			for len(data) > 0 {
+				bits, n := protowire.ConsumeFixed32(data)
+				if n < 0 {
+					return protowire.ParseError(n)
+				}
+				chunk.Embedding = append(chunk.Embedding, math.Float32frombits(bits))
+				data = data[n:]
+			}
+		case protoChunkMetadata:
+			return protoFields(data, func(num protowire.Number, data []byte, n uint64) error {
+				if num == protoMetadataExtra {
+					return decodeProtoExtra(&meta, data)
+				}
+				index, ok := protoFieldIndexes[num]
+				if !ok {
+					return nil
+				}
+				field := metaValue.Field(index)
+				switch field.Kind() {
+				case reflect.String:
+					field.SetString(string(data))
+				case reflect.Int:
+					field.SetInt(int64(int32(n)))
+				case reflect.Bool:
+					field.SetBool(n != 0)
+				case reflect.Slice:
+					field.Set(reflect.Append(field, reflect.ValueOf(string(data))))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return chunk, err
+	}
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return chunk, err
+	}
+	err = json.Unmarshal(encoded, &chunk.Metadata)
+	return chunk, err
+}
+
+func decodeProtoExtra(meta *extract.ChunkMetadata, entry []byte) error {
+	var key, value string
+	err := protoFields(entry, func(num protowire.Number, data []byte, _ uint64) error {
+		switch num {
+		case protoMapEntryKey:
+			key = string(data)
+		case protoMapEntryValue:
+			value = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return fmt.Errorf("invalid value of metadata %q: %w", key, err)
+	}
+	if meta.Extra == nil {
+		meta.Extra = make(map[string]interface{})
+	}
+	meta.Extra[key] = decoded
+	return nil
+}
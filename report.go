@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// tokenHistogramBounds are the inclusive upper bounds (in estimated tokens) of the
+// histogram buckets. Chunks above the last bound fall into a final open-ended bucket.
+var tokenHistogramBounds = []int{64, 128, 256, 512, 1024, 2048, 4096, 8192}
+
+// ChunkSizeReport summarizes chunk sizes so users can keep the embedding pipeline
+// within model context limits.
+type ChunkSizeReport struct {
+	TotalChunks     int                `json:"total_chunks"`
+	TotalBytes      int                `json:"total_bytes"`
+	TokenLimit      int                `json:"token_limit"`
+	ChunksOverLimit int                `json:"chunks_over_limit"`
+	MaxTokens       int                `json:"max_tokens"`
+	Histogram       []SizeBucket       `json:"histogram"`
+	Largest         []ChunkSizeOutlier `json:"largest"`
+}
+
+// SizeBucket is one histogram bucket keyed by estimated token count.
+type SizeBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
+
+// ChunkSizeOutlier describes one of the largest chunks along with suggested remedies.
+type ChunkSizeOutlier struct {
+	ID              string   `json:"id"`
+	EntityName      string   `json:"entity_name"`
+	EntityType      string   `json:"entity_type"`
+	FilePath        string   `json:"file_path"`
+	Bytes           int      `json:"bytes"`
+	EstimatedTokens int      `json:"estimated_tokens"`
+	Suggestions     []string `json:"suggestions"`
+}
+
+// buildChunkSizeReport computes the size histogram and lists the top largest chunks.
+func buildChunkSizeReport(chunks []extract.ChromaDocument, tokenLimit, top int) ChunkSizeReport {
+	report := ChunkSizeReport{
+		TotalChunks: len(chunks),
+		TokenLimit:  tokenLimit,
+	}
+
+	counts := make([]int, len(tokenHistogramBounds)+1)
+	for _, chunk := range chunks {
+		tokens := extract.EstimateTokens(chunk.Document)
+		report.TotalBytes += len(chunk.Document)
+		if tokens > report.MaxTokens {
+			report.MaxTokens = tokens
+		}
+		if tokens > tokenLimit {
+			report.ChunksOverLimit++
+		}
+		bucket := sort.SearchInts(tokenHistogramBounds, tokens)
+		counts[bucket]++
+	}
+
+	lower := 0
+	for i, upper := range tokenHistogramBounds {
+		report.Histogram = append(report.Histogram, SizeBucket{Range: fmt.Sprintf("%d-%d", lower, upper), Count: counts[i]})
+		lower = upper + 1
+	}
+	report.Histogram = append(report.Histogram, SizeBucket{Range: fmt.Sprintf(">%d", tokenHistogramBounds[len(tokenHistogramBounds)-1]), Count: counts[len(counts)-1]})
+
+	// Sort indices rather than the chunks themselves so the caller's slice is untouched.
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(chunks[order[i]].Document) > len(chunks[order[j]].Document)
+	})
+	if top > len(order) {
+		top = len(order)
+	}
+
+	for _, idx := range order[:top] {
+		chunk := chunks[idx]
+		filePath, _ := chunk.Metadata["file_path"].(string)
+		generated, _ := chunk.Metadata["generated"].(bool)
+
+		outlier := ChunkSizeOutlier{
+			ID:              chunk.ID,
+			FilePath:        filePath,
+			Bytes:           len(chunk.Document),
+			EstimatedTokens: extract.EstimateTokens(chunk.Document),
+		}
+		outlier.EntityName, _ = chunk.Metadata["entity_name"].(string)
+		outlier.EntityType, _ = chunk.Metadata["entity_type"].(string)
+		outlier.Suggestions = suggestSizeRemedies(outlier, generated, tokenLimit)
+		report.Largest = append(report.Largest, outlier)
+	}
+
+	return report
+}
+
+// suggestSizeRemedies proposes how to bring an oversized chunk within the token limit.
+func suggestSizeRemedies(outlier ChunkSizeOutlier, generated bool, tokenLimit int) []string {
+	suggestions := []string{}
+	if generated {
+		suggestions = append(suggestions, "exclude: file is generated code")
+	}
+	switch {
+	case outlier.EstimatedTokens > tokenLimit:
+		switch outlier.EntityType {
+		case "function", "method":
+			suggestions = append(suggestions, "split: function body exceeds the token limit; split at statement boundaries (-split-tokens)")
+		case "type_declaration":
+			suggestions = append(suggestions, "split: large type declaration; index fields or methods separately")
+		case "value_declaration":
+			suggestions = append(suggestions, "exclude: large literal/table value; consider excluding it from embedding")
+		default:
+			suggestions = append(suggestions, "split: chunk exceeds the token limit")
+		}
+	case outlier.EstimatedTokens > tokenLimit*3/4:
+		suggestions = append(suggestions, "review: chunk is close to the token limit")
+	}
+	return suggestions
+}
+
+// writeChunkSizeReport writes the report as indented JSON, encrypted if key is set.
+func writeChunkSizeReport(path string, report ChunkSizeReport, key []byte) error {
+	if err := writeJSONFileAtomic(path, report, key); err != nil {
+		return fmt.Errorf("failed to write size report: %w", err)
+	}
+	return nil
+}
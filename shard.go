@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// shardedSink splits the output into numbered files (code_chunks_0001.json,
+// code_chunks_0002.json, ...) of at most maxChunks chunks and about maxBytes bytes
+// each; a zero limit is not checked. Sizes are measured as the chunks' JSON encoding
+// before compression, and a chunk larger than maxBytes gets a file of its own.
+type shardedSink struct {
+	path      string
+	out       OutputOptions
+	maxChunks int
+	maxBytes  int64
+
+	shard  int // Number of the current shard, from 1.
+	sink   extract.Sink
+	chunks int
+	bytes  int64
+}
+
+// validateShardLimits checks -max-chunks-per-file and -max-bytes-per-file.
+func validateShardLimits(out OutputOptions) error {
+	if out.MaxChunksPerFile < 0 || out.MaxBytesPerFile < 0 {
+		return fmt.Errorf("shard limits must not be negative")
+	}
+	if (out.MaxChunksPerFile > 0 || out.MaxBytesPerFile > 0) && out.OutputFile == stdoutOutput {
+		return fmt.Errorf("output to stdout cannot be sharded")
+	}
+	return nil
+}
+
+// shardPath inserts the shard number before path's extensions: chunks.jsonl.gz becomes
+// chunks_0002.jsonl.gz.
+func shardPath(path string, shard int) string {
+	dir, base := filepath.Split(path)
+	name, ext := base, ""
+	if i := strings.Index(base, "."); i > 0 {
+		name, ext = base[:i], base[i:]
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%04d%s", name, shard, ext))
+}
+
+func (s *shardedSink) next() error {
+	if s.sink != nil {
+		if err := s.sink.Close(); err != nil {
+			return err
+		}
+	}
+	s.shard++
+	s.sink = newFileSink(shardPath(s.path, s.shard), s.out)
+	s.chunks, s.bytes = 0, 0
+	return nil
+}
+
+func (s *shardedSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	if s.sink == nil {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	for _, chunk := range chunks {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chunk %s: %w", chunk.ID, err)
+		}
+		size := int64(len(data))
+		full := (s.maxChunks > 0 && s.chunks >= s.maxChunks) ||
+			(s.maxBytes > 0 && s.chunks > 0 && s.bytes+size > s.maxBytes)
+		if full {
+			if err := s.next(); err != nil {
+				return err
+			}
+		}
+		if err := s.sink.Write(ctx, []extract.ChromaDocument{chunk}); err != nil {
+			return err
+		}
+		s.chunks++
+		s.bytes += size
+	}
+	return nil
+}
+
+func (s *shardedSink) Flush(ctx context.Context) error {
+	if s.sink == nil {
+		return nil
+	}
+	return s.sink.Flush(ctx)
+}
+
+// Abort aborts the current shard; shards completed before it are kept.
+func (s *shardedSink) Abort() error {
+	if s.sink == nil {
+		return nil
+	}
+	return extract.AbortSink(s.sink)
+}
+
+// Close closes the last shard and removes the higher-numbered shards a previous,
+// larger run may have left, so the shard files always form one chunk set.
+func (s *shardedSink) Close() error {
+	if s.sink == nil {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	if err := s.sink.Close(); err != nil {
+		return err
+	}
+	for shard := s.shard + 1; ; shard++ {
+		if err := os.Remove(shardPath(s.path, shard)); err != nil {
+			break
+		}
+	}
+	return nil
+}
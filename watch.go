@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+// chunkSnapshot is one complete, published generation of the chunk set.
+type chunkSnapshot struct {
+	Generation int
+	BuiltAt    time.Time
+	Chunks     []extract.ChromaDocument
+	// Fingerprints maps chunk IDs to content fingerprints; only kept when events are
+	// streamed, to diff consecutive generations.
+	Fingerprints map[string]string
+}
+
+// chunkWatcher rebuilds the chunk set in the background whenever sources change and
+// publishes it with double buffering: the previous generation stays current (in memory
+// and on disk) until the next one is completely built and written, then both are swapped
+// in one step. Consumers therefore never observe a partially updated package.
+type chunkWatcher struct {
+	projectPaths []string
+	opts         extract.ExtractOptions
+	out          OutputOptions
+	events       *eventBroker // nil unless chunk events are streamed
+
+	current atomic.Pointer[chunkSnapshot]
+}
+
+// Snapshot returns the most recently published generation (nil before the first build).
+func (w *chunkWatcher) Snapshot() *chunkSnapshot {
+	return w.current.Load()
+}
+
+// build extracts a new generation off to the side and publishes it if it succeeds.
+// A failed build leaves the previous generation in place.
+func (w *chunkWatcher) build(generation int) error {
+	ctx := context.Background()
+	result, err := extract.ExtractProjects(ctx, w.projectPaths, w.opts)
+	if err != nil {
+		return err
+	}
+	// emitExtraction replaces the output files via rename, so the on-disk swap is atomic too.
+	if err := emitExtraction(ctx, result, w.out); err != nil {
+		return err
+	}
+	snapshot := &chunkSnapshot{Generation: generation, BuiltAt: time.Now(), Chunks: result.Chunks}
+	if w.events != nil {
+		if snapshot.Fingerprints, err = snapshotFingerprints(result.Chunks); err != nil {
+			return err
+		}
+	}
+	previous := w.current.Swap(snapshot)
+	if w.events != nil {
+		w.events.publish(generation, diffSnapshots(previous, snapshot))
+	}
+	return nil
+}
+
+// runWatch performs an initial extraction and then polls the project, rebuilding in the
+// background whenever the source fingerprint changes. Changes that arrive while a build
+// is running trigger exactly one follow-up build once it finishes. If eventsAddr is set,
+// chunk add/update/delete events are streamed to subscribers on that address. It runs
+// until killed.
+func runWatch(projectPaths []string, opts extract.ExtractOptions, out OutputOptions, interval time.Duration, eventsAddr string) error {
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive, got %v", interval)
+	}
+	w := &chunkWatcher{projectPaths: projectPaths, opts: opts, out: out}
+	if eventsAddr != "" {
+		w.events = newEventBroker()
+		if err := w.events.listenEvents(eventsAddr); err != nil {
+			return err
+		}
+	}
+
+	builtFingerprint, err := sourcesFingerprint(projectPaths)
+	if err != nil {
+		return err
+	}
+	generation := 1
+	if err := w.build(generation); err != nil {
+		return fmt.Errorf("initial extraction failed: %w", err)
+	}
+	log.Printf("Watching %s for changes (generation %d published).", strings.Join(projectPaths, ", "), generation)
+
+	done := make(chan error, 1)
+	building := false
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			building = false
+			if err != nil {
+				log.Printf("Rebuild of generation %d failed; keeping generation %d: %v", generation, w.Snapshot().Generation, err)
+			} else {
+				log.Printf("Published generation %d.", generation)
+			}
+		case <-ticker.C:
+			if building {
+				continue // The fingerprint is re-checked once the running build completes.
+			}
+			fingerprint, err := sourcesFingerprint(projectPaths)
+			if err != nil {
+				log.Printf("Warning: could not scan sources: %v", err)
+				continue
+			}
+			if fingerprint == builtFingerprint {
+				continue
+			}
+			builtFingerprint = fingerprint
+			generation++
+			building = true
+			log.Printf("Sources changed; building generation %d in the background.", generation)
+			go func(gen int) { done <- w.build(gen) }(generation)
+		}
+	}
+}
+
+// sourcesFingerprint combines the source fingerprints of several project roots.
+func sourcesFingerprint(roots []string) (string, error) {
+	var combined strings.Builder
+	for _, root := range roots {
+		fingerprint, err := sourceFingerprint(root)
+		if err != nil {
+			return "", fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+		combined.WriteString(fingerprint)
+	}
+	return combined.String(), nil
+}
+
+// sourceFingerprint hashes the path, size and modification time of every file that can
+// affect extraction (Go sources and module files), skipping hidden directories and vendor.
+func sourceFingerprint(root string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") && name != "go.mod" && name != "go.sum" && name != "go.work" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
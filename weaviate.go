@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sunku5494/go-ast-chroma/extract"
+)
+
+const (
+	// defaultWeaviateClass is the class chunks are imported into.
+	defaultWeaviateClass = "GoCodeChunk"
+	// weaviateAPIKeyEnv names the environment variable holding the Weaviate API key.
+	weaviateAPIKeyEnv = "WEAVIATE_API_KEY"
+)
+
+// weaviateClassName matches the class names Weaviate accepts.
+var weaviateClassName = regexp.MustCompile(`^[A-Z][_0-9A-Za-z]*$`)
+
+// weaviateClient talks to the REST API of a Weaviate server.
+type weaviateClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// do sends body (if not nil) as JSON and decodes the response into result, if not nil.
+// It returns the response status code.
+func (c *weaviateClient) do(ctx context.Context, method, path string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach Weaviate: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read Weaviate response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("Weaviate returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(respBody)))
+	}
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode Weaviate response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// weaviateProperties is the class schema: the chunk ID and text, one property per
+// ChunkMetadata core field and the remaining metadata as JSON in metadata_extra.
+func weaviateProperties() []map[string]interface{} {
+	properties := []map[string]interface{}{
+		{"name": "chunk_id", "dataType": []string{"text"}, "tokenization": "field"},
+		{"name": "document", "dataType": []string{"text"}},
+	}
+	t := reflect.TypeOf(extract.ChunkMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		var dataType string
+		switch t.Field(i).Type.Kind() {
+		case reflect.Int:
+			dataType = "int"
+		case reflect.Bool:
+			dataType = "boolean"
+		case reflect.Slice:
+			dataType = "text[]"
+		default:
+			dataType = "text"
+		}
+		properties = append(properties, map[string]interface{}{"name": name, "dataType": []string{dataType}})
+	}
+	properties = append(properties, map[string]interface{}{
+		"name": "metadata_extra", "dataType": []string{"text"}, "indexSearchable": false, "indexFilterable": false,
+	})
+	return properties
+}
+
+// ensureClass creates the class unless it exists. Objects come with their vectors (or
+// none), so the class has no vectorizer unless one is given.
+func (c *weaviateClient) ensureClass(ctx context.Context, class, vectorizer string) error {
+	status, err := c.do(ctx, http.MethodGet, "/v1/schema/"+url.PathEscape(class), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return fmt.Errorf("failed to look up class %s: %w", class, err)
+	}
+	schema := map[string]interface{}{
+		"class":       class,
+		"description": "Go source chunks extracted by go-ast-chroma",
+		"vectorizer":  vectorizer,
+		"properties":  weaviateProperties(),
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/v1/schema", schema, nil); err != nil {
+		return fmt.Errorf("failed to create class %s: %w", class, err)
+	}
+	return nil
+}
+
+// weaviateObject converts a chunk into a batch import object. Weaviate IDs are UUIDs, so
+// the object ID is derived from the chunk ID, which is kept in chunk_id.
+func weaviateObject(class string, chunk extract.ChromaDocument) (map[string]interface{}, error) {
+	properties := map[string]interface{}{"chunk_id": chunk.ID, "document": chunk.Document}
+	extra := make(map[string]interface{})
+	for key, value := range chunk.Metadata {
+		switch {
+		case value == nil:
+		case extract.IsCoreMetadataKey(key):
+			properties[key] = value
+		default:
+			extra[key] = value
+		}
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata of chunk %s: %w", chunk.ID, err)
+	}
+	properties["metadata_extra"] = string(data)
+	object := map[string]interface{}{"class": class, "id": chunkUUID(chunk.ID), "properties": properties}
+	if chunk.Embedding != nil {
+		object["vector"] = chunk.Embedding
+	}
+	return object, nil
+}
+
+// chunkUUID derives a stable name-based (version 5) UUID from a chunk ID.
+func chunkUUID(id string) string {
+	// Namespace of chunk IDs: the URL namespace of RFC 4122.
+	namespace := []byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	sum := sha1.Sum(append(namespace, id...))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// importObjects imports a batch of objects, replacing objects with the same IDs, and
+// reports the objects Weaviate rejected.
+func (c *weaviateClient) importObjects(ctx context.Context, objects []map[string]interface{}) error {
+	var results []struct {
+		ID     string `json:"id"`
+		Result struct {
+			Errors *struct {
+				Error []struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"errors"`
+		} `json:"result"`
+	}
+	body := map[string]interface{}{"objects": objects}
+	if _, err := c.do(ctx, http.MethodPost, "/v1/batch/objects", body, &results); err != nil {
+		return err
+	}
+	var failed []string
+	for _, result := range results {
+		if result.Result.Errors == nil {
+			continue
+		}
+		for _, e := range result.Result.Errors.Error {
+			failed = append(failed, result.ID+": "+e.Message)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d objects were rejected: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// weaviateSink imports chunks into a Weaviate class in batches of batchSize as they are
+// written; Flush imports a partial batch. Chunks flagged embedding_skipped are left out.
+type weaviateSink struct {
+	client    *weaviateClient
+	class     string
+	batchSize int
+	pending   []map[string]interface{}
+	firstID   string
+	batches   int
+}
+
+// newWeaviateSink connects to the Weaviate server at baseURL and creates the class if
+// needed. The API key defaults to $WEAVIATE_API_KEY.
+func newWeaviateSink(ctx context.Context, baseURL, apiKey, class, vectorizer string, batchSize int) (*weaviateSink, error) {
+	if !weaviateClassName.MatchString(class) {
+		return nil, fmt.Errorf("invalid Weaviate class %q: must start with an upper-case letter and contain only letters, digits and _", class)
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv(weaviateAPIKeyEnv)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultChromaBatchSize
+	}
+	client := &weaviateClient{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, http: &http.Client{Timeout: 2 * time.Minute}}
+	if err := client.ensureClass(ctx, class, vectorizer); err != nil {
+		return nil, err
+	}
+	return &weaviateSink{client: client, class: class, batchSize: batchSize}, nil
+}
+
+func (s *weaviateSink) Write(ctx context.Context, chunks []extract.ChromaDocument) error {
+	for _, chunk := range chunks {
+		if skipped, _ := chunk.Metadata["embedding_skipped"].(bool); skipped {
+			continue
+		}
+		object, err := weaviateObject(s.class, chunk)
+		if err != nil {
+			return err
+		}
+		if len(s.pending) == 0 {
+			s.firstID = chunk.ID
+		}
+		s.pending = append(s.pending, object)
+		if len(s.pending) == s.batchSize {
+			if err := s.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *weaviateSink) Flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	s.batches++
+	if err := s.client.importObjects(ctx, s.pending); err != nil {
+		return fmt.Errorf("failed to import batch %d (%d chunks from %s): %w", s.batches, len(s.pending), s.firstID, err)
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+func (s *weaviateSink) Close() error {
+	return s.Flush(context.Background())
+}
+
+// String describes the destination in messages.
+func (s *weaviateSink) String() string {
+	return fmt.Sprintf("Weaviate class %s at %s", s.class, s.client.baseURL)
+}
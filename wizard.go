@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Projects above these sizes get incremental, low-priority extraction suggested.
+const (
+	largeProjectFiles = 2000
+	largeProjectBytes = 50 << 20
+)
+
+// ProjectProbe is what the init wizard learns about a project before asking questions.
+type ProjectProbe struct {
+	Dir        string `json:"dir"`
+	ModulePath string `json:"module_path,omitempty"`
+	GoVersion  string `json:"go_version,omitempty"`
+	GoFiles    int    `json:"go_files"`
+	GoBytes    int64  `json:"go_bytes"`
+	HasCmd     bool   `json:"has_cmd"`
+	GitRemote  string `json:"git_remote,omitempty"`
+}
+
+// probeProject inspects dir for its module, Go source size, cmd/ tree and git remote.
+func probeProject(dir string) (ProjectProbe, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ProjectProbe{}, err
+	}
+	probe := ProjectProbe{Dir: abs}
+
+	if data, err := ioutil.ReadFile(filepath.Join(abs, "go.mod")); err == nil {
+		if mod, err := modfile.ParseLax("go.mod", data, nil); err == nil {
+			if mod.Module != nil {
+				probe.ModulePath = mod.Module.Mod.Path
+			}
+			if mod.Go != nil {
+				probe.GoVersion = mod.Go.Version
+			}
+		}
+	}
+
+	err = filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != abs && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			if name == "cmd" {
+				probe.HasCmd = true
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".go") {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			probe.GoFiles++
+			probe.GoBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return probe, fmt.Errorf("failed to scan %s: %w", abs, err)
+	}
+
+	if remote, err := exec.Command("git", "-C", abs, "remote", "get-url", "origin").Output(); err == nil {
+		probe.GitRemote = strings.TrimSpace(string(remote))
+	}
+	return probe, nil
+}
+
+// wizardPrompter asks questions on a terminal. At end of input every remaining question
+// takes its default, so the wizard can also be driven non-interactively.
+type wizardPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// answer prints a question with a hint and returns the trimmed reply; eof reports that
+// input has ended.
+func (p *wizardPrompter) answer(question, hint string) (reply string, eof bool) {
+	fmt.Fprintf(p.out, "%s [%s]: ", question, hint)
+	line, err := p.in.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(p.out)
+	}
+	return strings.TrimSpace(line), err != nil
+}
+
+func (p *wizardPrompter) ask(question, def string) string {
+	if reply, _ := p.answer(question, def); reply != "" {
+		return reply
+	}
+	return def
+}
+
+func (p *wizardPrompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		reply, eof := p.answer(question, hint)
+		switch strings.ToLower(reply) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		if eof {
+			return def
+		}
+		fmt.Fprintln(p.out, "Please answer y or n.")
+	}
+}
+
+// runInitWizard probes a project, asks for the most common settings and writes a
+// starter config file.
+func runInitWizard(in io.Reader, out io.Writer) error {
+	p := &wizardPrompter{in: bufio.NewReader(in), out: out}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	dir := p.ask("Project directory", cwd)
+	probe, err := probeProject(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nProject:   %s\n", probe.Dir)
+	if probe.ModulePath != "" {
+		fmt.Fprintf(out, "Module:    %s (go %s)\n", probe.ModulePath, probe.GoVersion)
+	} else {
+		fmt.Fprintln(out, "Module:    no go.mod found; extraction needs a module or go.work workspace")
+	}
+	fmt.Fprintf(out, "Sources:   %d Go files, %.1f MiB\n", probe.GoFiles, float64(probe.GoBytes)/(1<<20))
+	if probe.GitRemote != "" {
+		fmt.Fprintf(out, "Remote:    %s\n", probe.GitRemote)
+	}
+	fmt.Fprintln(out)
+
+	large := probe.GoFiles > largeProjectFiles || probe.GoBytes > largeProjectBytes
+	flags := map[string]interface{}{}
+	metadata := map[string]interface{}{}
+	if probe.HasCmd && p.confirm("Exclude packages under cmd/ from the corpus?", false) {
+		flags["skip-cmd"] = true
+	}
+	if p.confirm("Exclude \"package main\" programs from the corpus?", false) {
+		flags["skip-main"] = true
+	}
+	if p.confirm("Link methods to their type and sibling methods (retrieval bundles)?", true) {
+		metadata["retrieval-bundles"] = true
+	}
+	if large {
+		fmt.Fprintln(out, "This is a large project; incremental, low-priority extraction is recommended.")
+	}
+	if p.confirm("Only emit chunks that changed since the previous run (incremental)?", large) {
+		flags["incremental"] = true
+	}
+	if p.confirm("Run as a low-priority background job (nice)?", large) {
+		flags["nice"] = true
+	}
+
+	path := p.ask("Config file (.yaml, .toml or .json)", defaultConfigFiles[0])
+	if _, err := os.Stat(path); err == nil && !p.confirm(path+" exists. Overwrite?", false) {
+		return fmt.Errorf("not overwriting %s", path)
+	}
+
+	// Store the root relative to the config file so the config can be checked in.
+	root := probe.Dir
+	if absPath, err := filepath.Abs(path); err == nil {
+		if rel, err := filepath.Rel(filepath.Dir(absPath), probe.Dir); err == nil {
+			root = rel
+		}
+	}
+	config := &ExtractConfig{Root: root, Output: defaultOutputFile, Project: &probe}
+	if len(metadata) > 0 {
+		config.Metadata = metadata
+	}
+	if len(flags) > 0 {
+		config.Flags = flags
+	}
+	if err := writeConfigFile(path, config); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s. Flags given on the command line override it.\n", path)
+	return nil
+}